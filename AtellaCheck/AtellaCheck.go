@@ -0,0 +1,194 @@
+package AtellaCheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// States of check, as in Nagios plugins
+	StateOk       string = "ok"
+	StateWarning  string = "warning"
+	StateCritical string = "critical"
+	StateUnknown  string = "unknown"
+)
+
+// Check configuration. Exportable and used in sector config. Fields are
+// used depending on check type. In string fields {host} is replaced by
+// address of checked host and {hostname} by its hostname
+type CheckConfig struct {
+	Name  string   `json:"name"`
+	Type  string   `json:"type"`
+	Hosts []string `json:"hosts"`
+	// Seconds, 0 - default timeout
+	Timeout int64 `json:"timeout"`
+	// tcp, tls
+	Port int64 `json:"port"`
+	// http: expected status (0 - 200) and regexp of body
+	Url    string `json:"url"`
+	Status int64  `json:"status"`
+	Body   string `json:"body"`
+	// dns: name to resolve, server address and expected address
+	Query  string `json:"query"`
+	Server string `json:"server"`
+	Expect string `json:"expect"`
+	// tls: certificate must be valid at least min_days
+	ServerName string `json:"server_name"`
+	MinDays    int64  `json:"min_days"`
+	// script: path, relative to scripts prefix or absolute, and arguments
+	Script string   `json:"script"`
+	Args   []string `json:"args"`
+	// Seconds between runs, 0 - every probe
+	Interval int64 `json:"interval"`
+}
+
+// Checked host
+type Target struct {
+	Host     string
+	Hostname string
+}
+
+// Result of check, saved into vector
+type Result struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Status    bool    `json:"status"`
+	State     string  `json:"state"`
+	Message   string  `json:"message"`
+	Duration  float64 `json:"duration"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Error of check with state other than critical
+type StateError struct {
+	State   string
+	Message string
+}
+
+func (e *StateError) Error() string {
+	return e.Message
+}
+
+// Function of check type. Return message about successful check or error.
+// Errors are critical, if they are not StateError
+type Checker func(ctx context.Context, config *CheckConfig,
+	target Target) (string, error)
+
+var (
+	checkersMutex sync.RWMutex
+	checkers      = map[string]Checker{
+		"tcp":    checkTcp,
+		"http":   checkHttp,
+		"dns":    checkDns,
+		"tls":    checkTls,
+		"script": checkScript}
+)
+
+// Function register check type. Existing type are overridden
+func Register(kind string, checker Checker) {
+	checkersMutex.Lock()
+	checkers[strings.ToLower(kind)] = checker
+	checkersMutex.Unlock()
+}
+
+// Function return true if check type are registered
+func IsType(kind string) bool {
+	checkersMutex.RLock()
+	_, exist := checkers[strings.ToLower(kind)]
+	checkersMutex.RUnlock()
+	return exist
+}
+
+// Function return true if check applies to host. Empty hosts list means
+// all hosts of sector
+func (config *CheckConfig) AppliesTo(target Target) bool {
+	if len(config.Hosts) < 1 {
+		return true
+	}
+	for _, h := range config.Hosts {
+		if h == target.Host || (target.Hostname != "" && h == target.Hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function return check name, type is used if name is not specifyed
+func (config *CheckConfig) GetName() string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return config.Type
+}
+
+// Function replace {host} and {hostname} in value
+func (target Target) Expand(value string) string {
+	hostname := target.Hostname
+	if hostname == "" {
+		hostname = target.Host
+	}
+	value = strings.Replace(value, "{hostname}", hostname, -1)
+	return strings.Replace(value, "{host}", target.Host, -1)
+}
+
+// Function run check with its timeout. DefaultTimeout are used if check
+// doesn.t specify timeout
+func Run(ctx context.Context, config *CheckConfig, target Target,
+	defaultTimeout time.Duration) Result {
+	res := Result{
+		Name:      config.GetName(),
+		Type:      config.Type,
+		Status:    false,
+		State:     StateUnknown,
+		Timestamp: time.Now().Unix()}
+
+	checkersMutex.RLock()
+	checker, exist := checkers[strings.ToLower(config.Type)]
+	checkersMutex.RUnlock()
+	if !exist {
+		res.Message = fmt.Sprintf("Unknown check type %s", config.Type)
+		return res
+	}
+
+	timeout := defaultTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	msg, err := checker(ctx, config, target)
+	res.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+	if err != nil {
+		res.State = StateCritical
+		if se, ok := err.(*StateError); ok {
+			res.State = se.State
+		}
+		res.Message = fmt.Sprintf("%s", err)
+		return res
+	}
+	res.Status = true
+	res.State = StateOk
+	res.Message = msg
+	return res
+}
+
+// Function run checks concurrently and return results in order of checks
+func RunAll(ctx context.Context, configs []*CheckConfig, target Target,
+	defaultTimeout time.Duration) []Result {
+	var wg sync.WaitGroup
+	results := make([]Result, len(configs))
+	for i, config := range configs {
+		wg.Add(1)
+		go func(i int, config *CheckConfig) {
+			defer wg.Done()
+			results[i] = Run(ctx, config, target, defaultTimeout)
+		}(i, config)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,154 @@
+package AtellaCheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	// Max size of HTTP body, which is matched by regexp
+	maxBodySize int64 = 1 << 20
+)
+
+// Function return address of target with port of check
+func address(config *CheckConfig, target Target) (string, error) {
+	if config.Port < 1 || config.Port > 65535 {
+		return "", fmt.Errorf("Bad port %d", config.Port)
+	}
+	return net.JoinHostPort(target.Host, fmt.Sprintf("%d", config.Port)), nil
+}
+
+// Check, that TCP port is open
+func checkTcp(ctx context.Context, config *CheckConfig, target Target) (string,
+	error) {
+	addr, err := address(config, target)
+	if err != nil {
+		return "", err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return fmt.Sprintf("Port %d is open", config.Port), nil
+}
+
+// Check, that HTTP answers with expected status and body
+func checkHttp(ctx context.Context, config *CheckConfig, target Target) (string,
+	error) {
+	expected := config.Status
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	req, err := http.NewRequest(http.MethodGet, target.Expand(config.Url), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if int64(res.StatusCode) != expected {
+		return "", fmt.Errorf("Status %d, expected %d", res.StatusCode, expected)
+	}
+	if config.Body == "" {
+		return fmt.Sprintf("Status %d", res.StatusCode), nil
+	}
+	re, err := regexp.Compile(config.Body)
+	if err != nil {
+		return "", fmt.Errorf("Bad body regexp - %s", err)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	if !re.Match(body) {
+		return "", fmt.Errorf("Body doesn't match %s", config.Body)
+	}
+	return fmt.Sprintf("Status %d, body matches", res.StatusCode), nil
+}
+
+// Check, that name resolves, optionally via specified server and into
+// expected address
+func checkDns(ctx context.Context, config *CheckConfig, target Target) (string,
+	error) {
+	query := target.Expand(config.Query)
+	if query == "" {
+		query = target.Expand("{hostname}")
+	}
+	resolver := net.DefaultResolver
+	if config.Server != "" {
+		server := target.Expand(config.Server)
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string,
+				addr string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			}}
+	}
+	addrs, err := resolver.LookupHost(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	if config.Expect == "" {
+		return fmt.Sprintf("%s resolves to %v", query, addrs), nil
+	}
+	expect := target.Expand(config.Expect)
+	for _, a := range addrs {
+		if a == expect {
+			return fmt.Sprintf("%s resolves to %s", query, expect), nil
+		}
+	}
+	return "", fmt.Errorf("%s resolves to %v, expected %s", query, addrs, expect)
+}
+
+// Check, that TLS certificate is valid at least min_days. Certificate chain
+// is not verified, it is a job of clients
+func checkTls(ctx context.Context, config *CheckConfig, target Target) (string,
+	error) {
+	addr, err := address(config, target)
+	if err != nil {
+		return "", err
+	}
+	serverName := target.Expand(config.ServerName)
+	if serverName == "" {
+		serverName = target.Expand("{hostname}")
+	}
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) < 1 {
+		return "", fmt.Errorf("No certificates")
+	}
+	notAfter := certs[0].NotAfter
+	days := int64(time.Until(notAfter).Hours() / 24)
+	if time.Now().After(notAfter) {
+		return "", fmt.Errorf("Certificate expired at %s", notAfter.Format(time.RFC3339))
+	}
+	if days < config.MinDays {
+		return "", fmt.Errorf("Certificate expires in %d days at %s", days,
+			notAfter.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("Certificate expires in %d days at %s", days,
+		notAfter.Format(time.RFC3339)), nil
+}
@@ -0,0 +1,93 @@
+package AtellaCheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var (
+	// Directory of check scripts with relative path
+	ScriptsPrefix string = "/usr/lib/atella/scripts"
+)
+
+// Function return path of check script. Relative paths are resolved
+// against ScriptsPrefix
+func (config *CheckConfig) ScriptPath() string {
+	if filepath.IsAbs(config.Script) {
+		return config.Script
+	}
+	return filepath.Join(ScriptsPrefix, config.Script)
+}
+
+// Function return state by exit code of Nagios plugin
+func exitState(code int) string {
+	switch code {
+	case 0:
+		return StateOk
+	case 1:
+		return StateWarning
+	case 2:
+		return StateCritical
+	}
+	return StateUnknown
+}
+
+// Run Nagios-style check script: exit code 0/1/2/3 means OK/WARNING/
+// CRITICAL/UNKNOWN and the first line of stdout is the message. Script
+// gets args and environment ATELLA_HOST and ATELLA_HOSTNAME
+func checkScript(ctx context.Context, config *CheckConfig, target Target) (string,
+	error) {
+	var stdout bytes.Buffer
+	if config.Script == "" {
+		return "", &StateError{State: StateUnknown, Message: "Script is not specifyed"}
+	}
+	args := make([]string, 0)
+	for _, a := range config.Args {
+		args = append(args, target.Expand(a))
+	}
+	cmd := exec.Command(config.ScriptPath(), args...)
+	cmd.Stdout = &stdout
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("ATELLA_HOST=%s", target.Host),
+		fmt.Sprintf("ATELLA_HOSTNAME=%s", target.Hostname))
+	// Script runs in own process group, so on timeout its children are
+	// killed too and don't keep stdout open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return "", &StateError{State: StateUnknown, Message: fmt.Sprintf("%s", err)}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	msg, _ := bufio.NewReader(&stdout).ReadString('\n')
+	msg = strings.TrimSpace(msg)
+	if ctx.Err() != nil {
+		return "", &StateError{State: StateCritical,
+			Message: fmt.Sprintf("Timeout of %s", config.Script)}
+	}
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return "", &StateError{State: StateUnknown, Message: fmt.Sprintf("%s", err)}
+		}
+		if msg == "" {
+			msg = fmt.Sprintf("%s", err)
+		}
+		return "", &StateError{State: exitState(exitErr.ExitCode()), Message: msg}
+	}
+	return msg, nil
+}
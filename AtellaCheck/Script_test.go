@@ -0,0 +1,69 @@
+package AtellaCheck
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExitState(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{0, StateOk},
+		{1, StateWarning},
+		{2, StateCritical},
+		{3, StateUnknown},
+		{-1, StateUnknown},
+		{127, StateUnknown},
+	}
+	for _, tt := range tests {
+		if got := exitState(tt.code); got != tt.want {
+			t.Errorf("exitState(%d) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestScriptPath(t *testing.T) {
+	saved := ScriptsPrefix
+	ScriptsPrefix = "/scripts"
+	defer func() { ScriptsPrefix = saved }()
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"check_disk", "/scripts/check_disk"},
+		{"nagios/check_disk", "/scripts/nagios/check_disk"},
+		{"/usr/bin/check_disk", "/usr/bin/check_disk"},
+	}
+	for _, tt := range tests {
+		config := &CheckConfig{Script: tt.script}
+		if got := config.ScriptPath(); got != tt.want {
+			t.Errorf("ScriptPath(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScriptTimeout(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "check.sh")
+	// Child keeps stdout open after script itself is killed
+	body := "#!/bin/sh\nsleep 30 &\nsleep 30\n"
+	if err := ioutil.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := checkScript(ctx, &CheckConfig{Script: script}, Target{})
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("checkScript returned after %s", time.Since(start))
+	}
+	serr, ok := err.(*StateError)
+	if !ok || serr.State != StateCritical {
+		t.Fatalf("checkScript error = %v, want critical timeout", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -18,26 +17,25 @@ import (
 )
 
 var (
-	conf              *AtellaConfig.Config = nil
-	cmd               string               = ""
-	msg               string               = "Test"
-	reportType        string               = "Test"
-	configFilePath    string               = ""
-	configDirPath     string               = ""
-	target            string               = "all"
-	printVersion      bool                 = false
-	printPidFile      bool                 = false
-	GitCommit         string               = "unknown"
-	GoVersion         string               = "unknown"
-	Version           string               = "unknown"
-	Service           string               = "Atella-Cli"
-	Arch              string               = "unknown"
-	Sys               string               = "unknown"
-	BinPrefix         string               = "/usr/bin"
-	ScriptsPrefix     string               = "/usr/lib/atella/scripts"
-	updateVersion     string               = "unknown"
-	PkgTemplate       string               = "atella_%s-1_%s.%s"
-	masterServerIndex int                  = 0
+	conf           *AtellaConfig.Config = nil
+	cmd            string               = ""
+	msg            string               = "Test"
+	reportType     string               = "Test"
+	configFilePath string               = ""
+	configDirPath  string               = ""
+	target         string               = "all"
+	printVersion   bool                 = false
+	printPidFile   bool                 = false
+	GitCommit      string               = "unknown"
+	GoVersion      string               = "unknown"
+	Version        string               = "unknown"
+	Service        string               = "Atella-Cli"
+	Arch           string               = "unknown"
+	Sys            string               = "unknown"
+	BinPrefix      string               = "/usr/bin"
+	ScriptsPrefix  string               = "/usr/lib/atella/scripts"
+	updateVersion  string               = "unknown"
+	PkgTemplate    string               = "atella_%s-1_%s.%s"
 )
 
 // Function initialize application runtime flags.
@@ -55,7 +53,8 @@ func initFlags() {
 			"Rotate\n\t"+
 			"Update\n\t"+
 			"WrapConfig\n\t"+
-			"Report")
+			"Report\n\t"+
+			"Status")
 	flag.StringVar(&msg, "message", "Test",
 		"Message. Work only with run mode \"Report\" & report type \"Custom\"")
 	flag.StringVar(&reportType, "type", "",
@@ -140,51 +139,50 @@ func Command() {
 		os.Exit(0)
 	case "update":
 		if updateVersion != "" {
-			for {
-				masterAddr := strings.Split(
-					conf.MasterServers.Hosts[conf.CurrentMasterServerIndex], " ")
-				masterconn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:5223",
-					masterAddr[0]), time.Duration(conf.Agent.NetTimeout)*time.Second)
-				if err != nil {
-					conf.CurrentMasterServerIndex =
-						conf.CurrentMasterServerIndex + 1
-					conf.CurrentMasterServerIndex =
-						conf.CurrentMasterServerIndex %
-							len(conf.MasterServers.Hosts)
-				} else {
+			// Masters are tried in order of priority
+			masterAddr := ""
+			for _, i := range conf.RankMasters() {
+				address := conf.GetMasterAddress(i)
+				masterconn, err := conf.DialName(address, conf.MasterName(address), 5223)
+				if err == nil {
 					masterconn.Close()
-					masterServerIndex = conf.CurrentMasterServerIndex
-					conf.Logger.LogSystem(fmt.Sprintf("[CLI] %s using for upgrade",
-						masterAddr[0]))
-					pkgName := fmt.Sprintf(PkgTemplate, updateVersion, Arch, Sys)
-					tmpPath := fmt.Sprintf("%s/%s", os.TempDir(), pkgName)
-					url := fmt.Sprintf("http://%s/download/pkg/%s/%s", masterAddr[0], Sys, pkgName)
-					err = DownloadFile(tmpPath, url)
-					if err != nil {
-						conf.Logger.LogError("[CLI] Failed download")
-						conf.Logger.LogFatal(fmt.Sprintf("[CLI] %s", err))
-					}
-					conf.Logger.LogSystem(fmt.Sprintf("[CLI] Downloaded %s", tmpPath))
-					switch Sys {
-					case "deb":
-						conf.Logger.LogSystem(fmt.Sprintf("[CLI] Debian system, install %s", tmpPath))
-						path, _ := exec.LookPath("dpkg")
-						err = syscall.Exec(path, []string{path, "-i", tmpPath}, os.Environ())
-						if err != nil {
-							conf.Logger.LogError("[CLI] Failed exec update")
-							conf.Logger.LogFatal(fmt.Sprintf("[CLI] %s", err))
-						}
-					}
+					masterAddr = address
 					break
 				}
-				if conf.CurrentMasterServerIndex == masterServerIndex {
-					conf.Logger.LogError("[CLI] Could not connect to any of masters")
-					break
+			}
+			if masterAddr == "" {
+				conf.Logger.LogError("[CLI] Could not connect to any of masters")
+				break
+			}
+			conf.Logger.LogSystem(fmt.Sprintf("[CLI] %s using for upgrade",
+				masterAddr))
+			pkgName := fmt.Sprintf(PkgTemplate, updateVersion, Arch, Sys)
+			tmpPath := fmt.Sprintf("%s/%s", os.TempDir(), pkgName)
+			url := fmt.Sprintf("http://%s/download/pkg/%s/%s", masterAddr, Sys, pkgName)
+			err = DownloadFile(tmpPath, url)
+			if err != nil {
+				conf.Logger.LogError("[CLI] Failed download")
+				conf.Logger.LogFatal(fmt.Sprintf("[CLI] %s", err))
+			}
+			conf.Logger.LogSystem(fmt.Sprintf("[CLI] Downloaded %s", tmpPath))
+			switch Sys {
+			case "deb":
+				conf.Logger.LogSystem(fmt.Sprintf("[CLI] Debian system, install %s", tmpPath))
+				path, _ := exec.LookPath("dpkg")
+				err = syscall.Exec(path, []string{path, "-i", tmpPath}, os.Environ())
+				if err != nil {
+					conf.Logger.LogError("[CLI] Failed exec update")
+					conf.Logger.LogFatal(fmt.Sprintf("[CLI] %s", err))
 				}
 			}
 		} else {
 			conf.Logger.LogError("[CLI] Version not specifyed")
 		}
+	case "status":
+		if err = printStatus(); err != nil {
+			conf.Logger.LogFatal(fmt.Sprintf("[CLI] %s", err))
+		}
+		os.Exit(0)
 	case "rotate":
 	default:
 		conf.Logger.LogError(fmt.Sprintf("[CLI] Unknown command: %s", cmd))
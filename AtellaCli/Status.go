@@ -0,0 +1,82 @@
+package AtellaCli
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Function send command to local agent and return reply without
+// "+OK ack {command}" prefix
+func queryAgent(conn net.Conn, reader *bufio.Reader, command string,
+	ack string) (string, error) {
+	conn.SetDeadline(time.Now().Add(
+		time.Duration(conf.Agent.NetTimeout) * time.Second))
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return "", err
+	}
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	msgMap := strings.SplitN(strings.TrimRight(reply, "\r\n"), " ", 4)
+	if msgMap[0] != "+OK" || len(msgMap) < 3 || msgMap[2] != ack {
+		return "", fmt.Errorf("Unexpected reply [%s]", strings.TrimSpace(reply))
+	}
+	if len(msgMap) < 4 {
+		return "", nil
+	}
+	return msgMap[3], nil
+}
+
+// Function print status of local agent: version, hostname and leader of
+// masters
+func printStatus() error {
+	// Certificate of agent is issued for its hostname, not for loopback
+	conn, err := conf.DialName("127.0.0.1", conf.Agent.Hostname, 5223)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if conf.UseChallenge() {
+		nonce, err := queryAgent(conn, reader, conf.GetAuthRequest(), "challenge")
+		if err != nil {
+			return err
+		}
+		_, err = queryAgent(conn, reader, conf.GetChallengeResponse(nonce), "auth")
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err = queryAgent(conn, reader, conf.GetPlainAuthRequest(), "auth")
+		if err != nil {
+			return err
+		}
+	}
+
+	version, err := queryAgent(conn, reader, "get version\n", "version")
+	if err != nil {
+		return err
+	}
+	hostname, err := queryAgent(conn, reader, "get hostname\n", "hostname")
+	if err != nil {
+		return err
+	}
+	fmt.Println("Hostname:", hostname)
+	fmt.Println("Version:", version)
+	if !conf.Agent.Master {
+		fmt.Println("Master: no")
+		return nil
+	}
+	leader, err := queryAgent(conn, reader, "get leader\n", "leader")
+	if err != nil {
+		return err
+	}
+	fmt.Println("Master: yes")
+	fmt.Println("Leader:", leader)
+	return nil
+}
@@ -0,0 +1,145 @@
+package AtellaClient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// State of local alerts about neighbour. Status is last reported status
+type alertState struct {
+	known     bool
+	status    bool
+	timestamp int64
+}
+
+// Function return true if agent reports neighbour status itself. It is
+// possible only if master servers are not specifyed
+func (client *ServerClient) localAlerts() bool {
+	return client.configuration.Agent.LocalAlerts &&
+		len(client.configuration.MasterServers.Hosts) < 1
+}
+
+// Function report about status change of neighbour, if agent is
+// responsible for neighbour and cooldown of last report passed. Status
+// changes of flapping neighbour are not reported
+func (client *ServerClient) alertNeighbour(c *neigbour) {
+	vec, exist := client.configuration.Vector.Get(c.address)
+	if !exist || !vec.IsProbed() {
+		return
+	}
+	// First probe sets status, it is not a change
+	if !c.alert.known {
+		c.alert = alertState{known: true, status: vec.Status}
+		return
+	}
+	if vec.Status == c.alert.status || vec.Flapping {
+		return
+	}
+
+	now := time.Now().Unix()
+	// Change is reported by next probe after cooldown if it is still actual
+	if now-c.alert.timestamp < client.configuration.Agent.AlertCooldown {
+		client.configuration.Logger.LogInfo(fmt.Sprintf(
+			"[Client] Neighbour [%s]. Alert suppressed by cooldown", c.address))
+		return
+	}
+	c.alert.status = vec.Status
+	if !client.isResponsible(c.address) {
+		client.configuration.Logger.LogInfo(fmt.Sprintf(
+			"[Client] Neighbour [%s]. Alert are left to other neighbour", c.address))
+		return
+	}
+	c.alert.timestamp = now
+
+	state := "down"
+	if vec.Status {
+		state = "up"
+	} else if vec.Reason != "" {
+		state = fmt.Sprintf("down (%s at %s)", vec.Reason, vec.Stage)
+	}
+	msg := fmt.Sprintf("Host %s [%s] in sector [%s] is %s. Reported by %s",
+		vec.Host, vec.Hostname, strings.Join(vec.Sectors, ", "), state,
+		client.configuration.Agent.Hostname)
+	client.configuration.Logger.LogSystem(fmt.Sprintf("[Client] %s", msg))
+	client.configuration.Report(msg, "all")
+}
+
+// Function report about appeared and resolved health problems of
+// neighbour, if agent is responsible for neighbour
+func (client *ServerClient) alertProblems(c *neigbour,
+	appeared []AtellaConfig.Problem, resolved []AtellaConfig.Problem) {
+	if len(appeared)+len(resolved) == 0 {
+		return
+	}
+	if !client.isResponsible(c.address) {
+		client.configuration.Logger.LogInfo(fmt.Sprintf(
+			"[Client] Neighbour [%s]. Problem alerts are left to other neighbour",
+			c.address))
+		return
+	}
+	vec, _ := client.configuration.Vector.Get(c.address)
+	for _, p := range appeared {
+		client.reportProblem(vec, fmt.Sprintf("Problem: %s", p.Message))
+	}
+	for _, p := range resolved {
+		client.reportProblem(vec, fmt.Sprintf("Resolved: %s", p.Message))
+	}
+}
+
+// Function create report about health problem of neighbour
+func (client *ServerClient) reportProblem(vec AtellaConfig.VectorType,
+	message string) {
+	msg := fmt.Sprintf("%s [%s] in sector [%s]. Reported by %s", message, vec.Host,
+		strings.Join(vec.Sectors, ", "), client.configuration.Agent.Hostname)
+	client.configuration.Logger.LogSystem(fmt.Sprintf("[Client] %s", msg))
+	client.configuration.Report(msg, "all")
+}
+
+// Function return true if agent is responsible for alerts about host.
+// Responsible is first host clockwise after host in sector ring, which is
+// not down. So one outage is reported by one agent
+func (client *ServerClient) isResponsible(host string) bool {
+	for _, i := range client.sectors {
+		hosts := client.configuration.Sectors[i].Config.Hosts
+		hostsCnt := len(hosts)
+		target, me := -1, -1
+		for j := 0; j < hostsCnt; j = j + 1 {
+			entry := strings.Split(hosts[j], " ")
+			if stringElExists(strings.Split(entry[0], ","), host) {
+				target = j
+			}
+			if stringElExists(entry, client.configuration.Agent.Hostname) {
+				me = j
+			}
+		}
+		if target < 0 || me < 0 {
+			continue
+		}
+		responsible := true
+		for j := (target + 1) % hostsCnt; j != me; j = (j + 1) % hostsCnt {
+			if !client.isDown(strings.Split(hosts[j], " ")[0]) {
+				responsible = false
+				break
+			}
+		}
+		if responsible {
+			return true
+		}
+	}
+	return false
+}
+
+// Function return true if all addresses of sector host entry are probed
+// and down. Hosts, which are not neighbours, are unknown and not down
+func (client *ServerClient) isDown(addresses string) bool {
+	for _, h := range strings.Split(addresses, ",") {
+		vec, exist := client.configuration.Vector.Get(h)
+		if !exist || !vec.IsProbed() || vec.Status {
+			return false
+		}
+	}
+	return true
+}
@@ -1,15 +1,14 @@
 package AtellaClient
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"math/rand"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
+	"../AtellaCheck"
 	"../AtellaConfig"
 )
 
@@ -18,31 +17,46 @@ const (
 	errMsg string = "-ERR"
 )
 
-var (
-	masterServerIndex int = 0
+const (
+	// Attempts of vector delivery to master in one iteration
+	deliveryAttempts int = 2
+	// Score, by which master must be better than current one to move to it
+	rebalanceScoreMargin float64 = 10
 )
 
 type ServerClient struct {
-	master        master
+	masters       []master
 	neighbours    []neigbour
+	replicas      []replica
+	local         neigbour
 	configuration *AtellaConfig.Config
-	stopRequest   chan struct{}
+	scheduler     *AtellaConfig.Scheduler
 	sectors       []int64
+	rebalanced    int64
+	// Index of current master in master_servers list, -1 without masters.
+	// It is read by status outside of scheduled jobs, so it is guarded
+	mux     sync.Mutex
+	current int
 }
 
 type neigbour struct {
-	conn            net.Conn
-	connError       bool
-	emptyMessageCnt uint64
-	stopReply       bool
-	address         string
-	port            int16
+	conn      net.Conn
+	session   *session
+	connError bool
+	address   string
+	hostname  string
+	port      int16
+	checks    []*AtellaCheck.CheckConfig
+	results   []AtellaCheck.Result
+	alert     alertState
+	problems  map[string]string
 }
 
 type master struct {
 	conn      net.Conn
+	session   *session
 	connError bool
-	stopReply bool
+	address   string
 }
 
 // Function send string via connection
@@ -67,189 +81,180 @@ func (c *neigbour) Close() error {
 	return c.conn.Close()
 }
 
+// Function schedule probes of neighbour every interval
 func (client *ServerClient) runNeighbour(c *neigbour) error {
-	var (
-		err      error = nil
-		fin      bool  = false
-		exit     bool  = false
-		vec      AtellaConfig.VectorType
-		status   bool          = false
-		msg      string        = ""
-		hostname string        = "unknown"
-		msgMap   []string      = []string{}
-		connbuf  *bufio.Reader = nil
-	)
-
 	client.configuration.Logger.LogInfo(fmt.Sprintf("[Client] Start routine for %s:%d",
 		c.address, c.port))
 
-	_, vectorIndex := client.configuration.GetVectorByHost(c.address)
-	if vectorIndex < 0 {
+	if _, exist := client.configuration.Vector.Get(c.address); !exist {
+		err := fmt.Errorf("Host [%s] are not present in vector array!", c.address)
 		client.configuration.Logger.LogError(fmt.Sprintf("[Client] %s", err))
-		return fmt.Errorf("Host [%s] are not present in vector array!", c.address)
+		return err
 	}
 
-	go func() {
-		<-client.stopRequest
-		exit = true
-		c.connError = true
-		if c.conn != nil {
-			c.conn.Close()
-		}
-	}()
-
-	// Infinity loop for requests
-	for {
-		AtellaConfig.Pause(client.configuration.Agent.Interval, &exit)
+	client.scheduler.Every(AtellaConfig.Seconds(client.configuration.Agent.Interval),
+		func(ctx context.Context) {
+			client.checkNeighbour(ctx, c)
+		})
+	return nil
+}
 
-		// If connection has error - reopen connection
-		if c.connError {
-			if exit {
-				break
+// Function make one iteration of neighbour routine: probe neighbour and
+// save result into vector
+func (client *ServerClient) checkNeighbour(ctx context.Context, c *neigbour) {
+	res := client.probe(ctx, c)
+	checks := client.runChecks(ctx, c)
+	// Probe and checks, interrupted by shutdown or reload, failed not by
+	// neighbour, so results are not saved
+	if ctx.Err() != nil {
+		return
+	}
+	problems := client.configuration.GetProblems(res.info)
+	appeared, resolved := client.updateProblems(c, res.info, problems)
+	if res.err != nil {
+		client.configuration.Logger.LogError(
+			fmt.Sprintf("[Client] Neighbour [%s]. Probe failed at %s [%s] - %s",
+				c.address, res.stage, res.reason, res.err))
+	}
+	client.configuration.Vector.Update(c.address,
+		func(vec *AtellaConfig.VectorType) {
+			conf := client.configuration.Agent
+			vec.ApplyProbe(res.ok(), time.Now().Unix(), conf.RiseCount,
+				conf.FailCount, conf.FlapCount, conf.FlapWindow)
+			if res.hostname != "" {
+				vec.Hostname = res.hostname
 			}
-			c.conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d",
-				c.address, c.port),
-				time.Duration(client.configuration.Agent.NetTimeout)*time.Second)
-			// if connection failed print error
-			if err != nil {
-				client.configuration.Logger.LogError(fmt.Sprintf("[Client] %s", err))
-				c.connError = true
-			} else {
-				c.connError = false
-				connbuf = bufio.NewReader(c.conn)
+			vec.Timestamp = time.Now().Unix()
+			vec.Stage = res.stage
+			vec.Reason = res.reason
+			vec.Error = ""
+			if res.err != nil {
+				vec.Error = res.err.Error()
 			}
-			continue
-		}
-
-		vec = client.configuration.Vector[vectorIndex]
-		fin = false
-		err = c.Send(fmt.Sprintf("auth %s\n", client.configuration.Security.Code))
-		if err != nil {
-			status = false
-			c.connError = true
-			client.configuration.Logger.LogError(
-				fmt.Sprintf("[Client] neighbour [%s]. Security - %s", c.address, err))
-			continue
-		}
-
-		// auth - ack - hostname - ack - host - ack
-		for {
-			if fin {
-				vec.Status = status
-				vec.Hostname = hostname
-				vec.Timestamp = time.Now().Unix()
-				client.configuration.Vector[vectorIndex] = vec
-				break
+			if res.dialed {
+				vec.DialTime.Add(res.dialTime, conf.RttWindow)
 			}
-
-			message, err := connbuf.ReadString('\n')
-			if err != nil && err != io.EOF {
-				fin = true
-				status = false
-				c.connError = true
-				vec.Status = status
-				client.configuration.Vector[vectorIndex] = vec
-				client.configuration.Logger.LogError(
-					fmt.Sprintf("[Client] Neighbour [%s]. Read - %s", c.address, err))
-				continue
+			if len(c.checks) > 0 {
+				vec.Checks = checks
 			}
-
-			msg = strings.TrimRight(message, "\r\n")
-			msgMap = strings.Split(msg, " ")
-			client.configuration.Logger.LogInfo(
-				fmt.Sprintf("[Client] Neighbour [%s]. Receive [%s]", c.address, msg))
-
-			if msg == "" {
-				if c.emptyMessageCnt > 5 {
-					client.configuration.Logger.LogWarning(
-						fmt.Sprintf("[Client] Received %d empty messages. Force closing connection.",
-							c.emptyMessageCnt))
-					c.connError = true
-					fin = true
-					continue
-				}
-				c.emptyMessageCnt = c.emptyMessageCnt + 1
-			} else {
-				c.emptyMessageCnt = 0
+			if res.info != nil {
+				vec.Info = res.info
+				vec.Problems = problems
 			}
-
-			if msgMap[0] == errMsg {
-				client.configuration.Logger.LogError(
-					fmt.Sprintf("[Client] Neighbour [%s]. Receive %s", c.address, errMsg))
-				continue
-			} else if msgMap[0] != okMsg {
-				client.configuration.Logger.LogError(
-					fmt.Sprintf("[Client] Neighbour [%s]. Receive !%s", c.address, okMsg))
-				continue
+			if res.ok() {
+				vec.Latency = res.latency
+				vec.Rtt.Add(res.latency, conf.RttWindow)
+				vec.LastSeen = vec.Timestamp
 			}
+		})
+	if client.localAlerts() {
+		client.alertNeighbour(c)
+		client.alertProblems(c, appeared, resolved)
+	}
+}
 
-			switch msgMap[1] {
-			case "ack":
-				switch msgMap[2] {
-				case "auth":
-					err = c.Send("get hostname\n")
-					if err != nil {
-						status = false
-						fin = true
-						c.connError = true
-						client.configuration.Logger.LogError(
-							fmt.Sprintf("[Client] Neighbour [%s]. Send hostname - %s",
-								c.address, err))
-					}
-				case "hostname":
-					if len(msgMap) < 4 {
-						fin = true
-						status = false
-						client.configuration.Logger.LogError(
-							fmt.Sprintf("[Client] Neighbour [%s]. Msg len expected ack < 4",
-								c.address))
-					}
-					hostname = msgMap[3]
-					err = c.Send(fmt.Sprintf("set host %s\n", client.configuration.Agent.Hostname))
-					if err != nil {
-						fin = true
-						status = false
-						c.connError = true
-						client.configuration.Logger.LogError(
-							fmt.Sprintf("[Client] Neighbour [%s]. Send host - %s",
-								c.address, err))
-					}
-				case "host":
-					if len(msgMap) < 4 {
-						fin = true
-						status = false
-						client.configuration.Logger.LogError(
-							fmt.Sprintf("[Client] Neighbour [%s]. Msg len expected ack < 4",
-								c.address))
-					}
-					if msgMap[3] == client.configuration.Agent.Hostname {
-						status = true
-					} else {
-						status = false
-					}
-					vec.Status = status
-					vec.Hostname = hostname
-					vec.Timestamp = time.Now().Unix()
-					client.configuration.Vector[vectorIndex] = vec
-				}
+// Function save current health problems of neighbour and log changes.
+// Return appeared and resolved problems. Without info problems are
+// unknown and left as is
+func (client *ServerClient) updateProblems(c *neigbour,
+	info *AtellaConfig.HostInfo,
+	problems []AtellaConfig.Problem) ([]AtellaConfig.Problem, []AtellaConfig.Problem) {
+	appeared := make([]AtellaConfig.Problem, 0)
+	resolved := make([]AtellaConfig.Problem, 0)
+	if info == nil {
+		return appeared, resolved
+	}
+	current := make(map[string]string)
+	for _, p := range problems {
+		current[p.Key] = p.Message
+		if _, exist := c.problems[p.Key]; !exist {
+			appeared = append(appeared, p)
+			client.configuration.Logger.LogWarning(fmt.Sprintf(
+				"[Client] Neighbour [%s]. Problem %s", c.address, p.Message))
+		}
+	}
+	for key, message := range c.problems {
+		if _, exist := current[key]; !exist {
+			resolved = append(resolved, AtellaConfig.Problem{
+				Key:     key,
+				Message: message})
+			client.configuration.Logger.LogInfo(fmt.Sprintf(
+				"[Client] Neighbour [%s]. Resolved %s", c.address, message))
+		}
+	}
+	c.problems = current
+	return appeared, resolved
+}
 
-			}
+// Function run sector checks of neighbour, which are due by their interval,
+// and report about changes of check state. Return results of all checks.
+// Results of checks, interrupted by context, are discarded
+func (client *ServerClient) runChecks(ctx context.Context,
+	c *neigbour) []AtellaCheck.Result {
+	if len(c.checks) < 1 {
+		return nil
+	}
+	now := time.Now().Unix()
+	due := make([]*AtellaCheck.CheckConfig, 0)
+	index := make([]int, 0)
+	for i, check := range c.checks {
+		last := c.results[i].Timestamp
+		if last == 0 || check.Interval <= 0 || now-last >= check.Interval {
+			due = append(due, check)
+			index = append(index, i)
 		}
 	}
-	c.stopReply = true
-	client.configuration.Logger.LogSystem(
-		fmt.Sprintf("Routine for %s:%d stopped",
-			c.address, c.port))
-	return nil
+
+	target := AtellaCheck.Target{
+		Host:     c.address,
+		Hostname: c.hostname}
+	results := AtellaCheck.RunAll(ctx, due, target,
+		AtellaConfig.Seconds(int64(client.configuration.Agent.NetTimeout)))
+	if ctx.Err() != nil {
+		return nil
+	}
+	for j, r := range results {
+		prev := c.results[index[j]]
+		c.results[index[j]] = r
+		if !r.Status {
+			client.configuration.Logger.LogWarning(fmt.Sprintf(
+				"[Client] Host [%s]. Check %s [%s] is %s - %s",
+				c.address, r.Name, r.Type, r.State, r.Message))
+		}
+		// Check was never made, it is not a change
+		if prev.Timestamp != 0 && prev.State != r.State {
+			client.reportCheck(c, prev, r)
+		}
+	}
+
+	res := make([]AtellaCheck.Result, len(c.results))
+	copy(res, c.results)
+	return res
 }
 
-// Run client
+// Function create report about change of check state
+func (client *ServerClient) reportCheck(c *neigbour, prev AtellaCheck.Result,
+	cur AtellaCheck.Result) {
+	hostname := c.hostname
+	if hostname == "" {
+		hostname = "unknown"
+	}
+	msg := fmt.Sprintf("Check %s [%s] of host %s [%s] is %s (was %s): %s. Reported by %s",
+		cur.Name, cur.Type, c.address, hostname, cur.State, prev.State, cur.Message,
+		client.configuration.Agent.Hostname)
+	client.configuration.Logger.LogSystem(fmt.Sprintf("[Client] %s", msg))
+	client.configuration.Report(msg, "all")
+}
+
+// Run client. Routines only schedule jobs, so they are called in place:
+// all jobs are scheduled before Stop or Reload waits for them
 func (c *ServerClient) Run() {
-	// Init neighbours goroutines
 	for n := 0; n < len(c.neighbours); n = n + 1 {
-		go c.runNeighbour(&c.neighbours[n])
+		c.runNeighbour(&c.neighbours[n])
 	}
-	go c.runMasterClient()
+	c.runMasterClient()
+	c.runReplication()
+	c.runLocalChecks()
 }
 
 // New client
@@ -263,24 +268,42 @@ func (c *ServerClient) init(configuration *AtellaConfig.Config) {
 	c.neighbours = make([]neigbour, 0)
 	c.sectors = make([]int64, 0)
 	c.configuration = configuration
-	c.configuration.Vector = make([]AtellaConfig.VectorType, 0)
-	c.stopRequest = make(chan struct{})
+	c.configuration.Vector.Reset()
+	c.scheduler = AtellaConfig.NewScheduler(context.Background())
 
-	// Selecting pseudo-random master from config
+	// Selecting best master from config
 	if len(c.configuration.MasterServers.Hosts) < 1 {
-		c.configuration.CurrentMasterServerIndex = -1
+		c.setCurrentMaster(-1)
 		c.configuration.Logger.LogWarning(fmt.Sprintf("Master servers not specifiyed!"))
+		if c.configuration.Agent.LocalAlerts {
+			c.configuration.Logger.LogSystem("Neighbours status are reported by me")
+		}
 	} else if !c.configuration.Agent.Master {
-		masterServerIndex = rand.Int() % len(c.configuration.MasterServers.Hosts)
-		c.configuration.CurrentMasterServerIndex = 0
+		c.setCurrentMaster(c.configuration.RankMasters()[0])
+		c.rebalanced = time.Now().Unix()
 		c.configuration.Logger.LogSystem(fmt.Sprintf("Use [%s] as master server",
-			c.configuration.MasterServers.Hosts[masterServerIndex]))
+			c.configuration.MasterServers.Hosts[c.CurrentMaster()]))
 	}
 
+	c.initMasters()
 	c.GetMySector()
+	c.initReplicas()
+	c.initLocalChecks()
 	c.configuration.Logger.LogSystem("Init client side")
 }
 
+// Function fill master connections by master servers from config
+func (c *ServerClient) initMasters() {
+	c.masters = make([]master, 0)
+	for _, host := range c.configuration.MasterServers.Hosts {
+		c.masters = append(c.masters, master{
+			conn:      nil,
+			session:   nil,
+			connError: true,
+			address:   strings.Split(host, " ")[0]})
+	}
+}
+
 // Function find and save sector indexes
 func (c *ServerClient) GetMySector() {
 	var (
@@ -307,11 +330,13 @@ func (c *ServerClient) GetMySector() {
 						c.configuration.Sectors[i].Config.Hosts[(j-l+hostsCnt)%hostsCnt], " ")
 					// if next host is not me
 					if !stringElExists(hosts_next, c.configuration.Agent.Hostname) {
-						c.AddHost(hosts_next[0], c.configuration.Sectors[i].Sector)
+						c.AddHost(hosts_next[0], sectorHostname(hosts_next),
+							c.configuration.Sectors[i])
 					}
 					// if prev host is not me
 					if !stringElExists(hosts_prev, c.configuration.Agent.Hostname) {
-						c.AddHost(hosts_prev[0], c.configuration.Sectors[i].Sector)
+						c.AddHost(hosts_prev[0], sectorHostname(hosts_prev),
+							c.configuration.Sectors[i])
 					}
 				}
 			}
@@ -320,16 +345,28 @@ func (c *ServerClient) GetMySector() {
 	c.sectors = sector
 }
 
-// Function add non-existing host in vector and neighbours array
-func (c *ServerClient) AddHost(host string, sector string) {
-	var vec AtellaConfig.VectorType
+// Function return hostname of sector host entry "ip hostname" or empty
+// string if entry has only address
+func sectorHostname(entry []string) string {
+	if len(entry) < 2 {
+		return ""
+	}
+	return entry[1]
+}
+
+// Function add non-existing host in vector and neighbours array. Hostname
+// is expected answer of neighbour, empty hostname is not checked. Checks
+// of sector, which applies to host, are added to neighbour
+func (c *ServerClient) AddHost(host string, hostname string,
+	sectorConfig *AtellaConfig.SectorsConfig) {
+	sector := sectorConfig.Sector
 	hosts := strings.Split(host, ",")
 	for _, h := range hosts {
 		// Getting vector index for current host
-		_, index := c.configuration.GetVectorByHost(h)
+		vec, exist := c.configuration.Vector.Get(h)
 
 		// If host doesn.t have a vector - create new, else use existing
-		if index < 0 {
+		if !exist {
 			vec = AtellaConfig.VectorType{
 				Host:      h,
 				Hostname:  "unknown",
@@ -337,8 +374,6 @@ func (c *ServerClient) AddHost(host string, sector string) {
 				Interval:  c.configuration.Agent.Interval,
 				Timestamp: 0,
 				Sectors:   make([]string, 0)}
-		} else {
-			vec = c.configuration.Vector[index]
 		}
 		// Save time of change
 		vec.Timestamp = time.Now().Unix()
@@ -354,25 +389,69 @@ func (c *ServerClient) AddHost(host string, sector string) {
 		// If a neighbour doesn.t added, adding host
 		if !neighbourElExistsByAddress(c.neighbours, h) {
 			n := neigbour{
-				conn:            nil,
-				connError:       true,
-				address:         h,
-				port:            5223,
-				emptyMessageCnt: 0}
+				conn:      nil,
+				session:   nil,
+				connError: true,
+				address:   h,
+				hostname:  hostname,
+				port:      5223}
 			c.neighbours = append(c.neighbours, n)
 			c.configuration.Logger.LogInfo(fmt.Sprintf("Added a neighbour host [%s]",
 				h))
 		}
+		c.addChecks(h, hostname, sectorConfig.Config.Checks)
 
 		// If the vector did not exist, saving, else - override existing
-		if index < 0 {
-			c.configuration.Vector = append(c.configuration.Vector, vec)
-		} else {
-			c.configuration.Vector[index] = vec
+		c.configuration.Vector.Set(vec)
+	}
+}
+
+// Function add checks, which applies to host, to neighbour
+func (c *ServerClient) addChecks(host string, hostname string,
+	checks []*AtellaCheck.CheckConfig) {
+	target := AtellaCheck.Target{
+		Host:     host,
+		Hostname: hostname}
+	for i := range c.neighbours {
+		if c.neighbours[i].address != host {
+			continue
+		}
+		for _, check := range checks {
+			// Host may be added several times by the same sector
+			if checkExists(c.neighbours[i].checks, check) {
+				continue
+			}
+			if check.AppliesTo(target) {
+				c.neighbours[i].checks = append(c.neighbours[i].checks, check)
+				c.neighbours[i].results = append(c.neighbours[i].results,
+					pendingResult(check))
+				c.configuration.Logger.LogInfo(fmt.Sprintf("Added check %s [%s] for host [%s]",
+					check.GetName(), check.Type, host))
+			}
 		}
 	}
 }
 
+// Function return result of check, which was not made yet
+func pendingResult(check *AtellaCheck.CheckConfig) AtellaCheck.Result {
+	return AtellaCheck.Result{
+		Name:    check.GetName(),
+		Type:    check.Type,
+		State:   AtellaCheck.StateUnknown,
+		Message: "Not checked yet"}
+}
+
+// Function check checks array and return true if check exist
+func checkExists(array []*AtellaCheck.CheckConfig,
+	item *AtellaCheck.CheckConfig) bool {
+	for i := 0; i < len(array); i = i + 1 {
+		if array[i] == item {
+			return true
+		}
+	}
+	return false
+}
+
 // Function check string array and return true if item exist
 func stringElExists(array []string, item string) bool {
 	for i := 0; i < len(array); i = i + 1 {
@@ -403,145 +482,191 @@ func neighbourElExistsByAddress(array []neigbour, addr string) bool {
 	return false
 }
 
-// Function starts and handle connection to master server
+// Function schedule sending of vector to master servers every interval.
+// Vector are sent to current master with failover or to all masters in
+// parallel, if fan-out enabled
 func (c *ServerClient) runMasterClient() error {
-	var (
-		err        error = nil
-		masterAddr []string
-		exit       bool = false
-	)
-	c.master.connError = true
-
 	// Exit if we don.t have master servers
-	if c.configuration.CurrentMasterServerIndex < 0 {
+	if c.CurrentMaster() < 0 {
 		return fmt.Errorf("Master servers not specifiyed")
 	}
 
-	go func() {
-		<-c.stopRequest
-		exit = true
-		c.master.connError = true
-		if c.master.conn != nil {
-			c.master.conn.Close()
+	// If i am a master server, local vector saved by master server routine
+	if c.configuration.Agent.Master {
+		return nil
+	}
+
+	if c.configuration.Agent.MasterFanout {
+		for i := 0; i < len(c.masters); i = i + 1 {
+			m := &c.masters[i]
+			c.scheduler.Every(AtellaConfig.Seconds(c.configuration.Agent.Interval),
+				func(ctx context.Context) {
+					c.deliverVector(ctx, m)
+				})
 		}
-	}()
+		return nil
+	}
 
-	// Loop because link to current master server may be broken
-	for !exit {
-		AtellaConfig.Pause(c.configuration.Agent.Interval, &exit)
+	c.scheduler.Every(AtellaConfig.Seconds(c.configuration.Agent.Interval),
+		func(ctx context.Context) {
+			c.checkMaster(ctx)
+		})
+	return nil
+}
 
-		// If i am a master server, save client vector to local master vector
-		if c.configuration.Agent.Master {
-			var vec []AtellaConfig.VectorType
-			json.Unmarshal(c.configuration.GetJsonVector(), &vec)
-			c.configuration.MasterVector[c.configuration.Agent.Hostname] = vec
+// Function return index of current master in master_servers list or -1
+// if there are no masters
+func (c *ServerClient) CurrentMaster() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.current
+}
+
+// Function save index of current master
+func (c *ServerClient) setCurrentMaster(i int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.current = i
+}
+
+// Function make one iteration of master client: send vector to current
+// master. Preferred master are tried first every rebalance interval. If
+// delivery failed, other masters are tried in order of rank
+func (c *ServerClient) checkMaster(ctx context.Context) {
+	conf := c.configuration
+	cur := c.CurrentMaster()
+	order := []int{cur}
+	if preferred := c.preferredMaster(); preferred >= 0 {
+		order = []int{preferred, cur}
+	}
+	order = append(order, conf.RankMasters()...)
+
+	tried := make(map[int]bool)
+	for _, i := range order {
+		if tried[i] || ctx.Err() != nil {
 			continue
 		}
-
-		// If connection has error - reopen connection
-		if c.master.connError {
-			if exit {
-				break
-			}
-			for !exit {
-				masterAddr = strings.Split(
-					c.configuration.MasterServers.Hosts[c.configuration.CurrentMasterServerIndex], " ")
-				c.master.conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d",
-					masterAddr[0], 5223),
-					time.Duration(c.configuration.Agent.NetTimeout)*time.Second)
-				// if connection failed print error
-				if err != nil {
-					c.configuration.Logger.LogError(fmt.Sprintf("%s", err))
-					// If connection have any of errors - try next server
-					c.configuration.CurrentMasterServerIndex =
-						c.configuration.CurrentMasterServerIndex + 1
-					c.configuration.CurrentMasterServerIndex =
-						c.configuration.CurrentMasterServerIndex %
-							len(c.configuration.MasterServers.Hosts)
-					c.master.connError = true
-
-					// If we try all servers and all servers unreacheble - return error
-					if c.configuration.CurrentMasterServerIndex == masterServerIndex {
-						c.configuration.Logger.LogError("Could not connect to any of masters")
-						// 	return fmt.Errorf("Could not connect to any of masters")
-					}
-				} else {
-					c.master.connError = false
-					masterServerIndex = c.configuration.CurrentMasterServerIndex
-					break
-				}
-			}
+		tried[i] = true
+		if c.deliverVector(ctx, &c.masters[i]) != nil {
+			continue
 		}
+		if i != cur {
+			conf.Logger.LogSystem(fmt.Sprintf("[Client] Switched master from %s to %s",
+				c.masters[cur].address, c.masters[i].address))
+			c.setCurrentMaster(i)
+		}
+		return
+	}
+	if ctx.Err() == nil {
+		conf.Logger.LogError("Could not deliver vector to any of masters")
+	}
+}
+
+// Function return index of master, which is better than current one, if
+// rebalance interval passed. Better master has higher priority or much
+// higher score. Else return -1
+func (c *ServerClient) preferredMaster() int {
+	conf := c.configuration
+	now := time.Now().Unix()
+	if now-c.rebalanced < conf.MasterServers.RebalanceInterval {
+		return -1
+	}
+	c.rebalanced = now
+	cur := c.CurrentMaster()
+	best := conf.RankMasters()[0]
+	if best == cur {
+		return -1
+	}
+	if conf.GetMasterPriority(best) > conf.GetMasterPriority(cur) ||
+		conf.GetMasterScore(best) > conf.GetMasterScore(cur)+rebalanceScoreMargin {
+		conf.Logger.LogInfo(fmt.Sprintf("[Client] Trying preferred master %s",
+			c.masters[best].address))
+		return best
+	}
+	return -1
+}
 
-		// If connection is ok, send vector
-		c.sendVectorToMaster(
-			[]byte(fmt.Sprintf("set vector %s %s\n", c.configuration.Agent.Hostname,
-				c.configuration.GetJsonVector())))
+// Function send vector to master and check acknowledgement. Broken
+// connection, e.g. half-open one, are reopened and sending are retried.
+// Result are counted in delivery health of master
+func (c *ServerClient) deliverVector(ctx context.Context, m *master) error {
+	var (
+		err     error   = nil
+		latency float64 = 0
+	)
+	for attempt := 0; attempt < deliveryAttempts && ctx.Err() == nil; attempt = attempt + 1 {
+		if latency, err = c.sendVectorToMaster(ctx, m); err == nil {
+			break
+		}
+		c.configuration.Logger.LogError(fmt.Sprintf(
+			"[Client] Master [%s] attempt %d - %s", m.address, attempt+1, err))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	c.master.stopReply = true
-	c.configuration.Logger.LogSystem(fmt.Sprintf("Master client connection stoped"))
-	return nil
+	prev := c.configuration.GetDeliveryStats()[m.address]
+	stats := c.configuration.CountDelivery(m.address, latency, err)
+	if err != nil {
+		c.configuration.Logger.LogError(fmt.Sprintf(
+			"[Client] Master [%s] delivery failed %d times in a row",
+			m.address, stats.ConsecutiveFailures))
+	} else if prev.ConsecutiveFailures > 0 {
+		c.configuration.Logger.LogSystem(fmt.Sprintf(
+			"[Client] Master [%s] delivery restored after %d failures",
+			m.address, prev.ConsecutiveFailures))
+	}
+	return err
 }
 
-// Function send vector to one of master servers
-func (c *ServerClient) sendVectorToMaster(query []byte) error {
+// Function send vector to master, reopen connection if it has error.
+// Return duration of auth and sending in milliseconds
+func (c *ServerClient) sendVectorToMaster(ctx context.Context,
+	m *master) (float64, error) {
 	var err error = nil
 
-	_, err = c.master.conn.Write(
-		[]byte(fmt.Sprintf("auth %s\n", c.configuration.Security.Code)))
+	if m.connError {
+		m.conn, err = c.configuration.DialContext(ctx, m.address, 5223)
+		if err != nil {
+			m.conn = nil
+			return 0, err
+		}
+		m.session = newSession(m.conn, c.configuration)
+		if err = m.session.Hello(); err != nil {
+			m.conn.Close()
+			return 0, fmt.Errorf("hello - %s", err)
+		}
+		m.connError = false
+	}
+	start := time.Now()
+
+	m.conn.SetDeadline(time.Now().Add(
+		time.Duration(c.configuration.Agent.NetTimeout) * time.Second))
+	defer m.conn.SetDeadline(time.Time{})
 
+	err = m.session.Auth()
 	if err != nil {
-		c.master.connError = true
-		c.configuration.Logger.LogError(
-			fmt.Sprintf("[Client] master connection [security]. Error - %s",
-				err))
-		return err
+		err = fmt.Errorf("security - %s", err)
+	} else {
+		// Reply are checked, so vector are not lost on broken connection
+		err = m.session.SetVector(c.configuration.Agent.Hostname,
+			c.configuration.Vector.Snapshot())
 	}
-
-	_, err = c.master.conn.Write(query)
 	if err != nil {
-		c.master.connError = true
-		c.configuration.Logger.LogError(
-			fmt.Sprintf("[Client] master connection [query]. Error - %s",
-				err))
-		return err
+		m.connError = true
+		m.conn.Close()
 	}
-
-	return err
+	return float64(time.Since(start)) / float64(time.Millisecond), err
 }
 
 func (client *ServerClient) Reload(c *AtellaConfig.Config) {
 	client.configuration.Logger.LogSystem("[Client] Reloading client")
 
-	// Trying accuire the lock
-	close(client.stopRequest)
-	// client.master.stopRequest = true
-	// for i := 0; i < len(client.neighbours); i = i + 1 {
-	// 	client.neighbours[i].stopRequest = true
-	// }
-
-	// Wait until the lock is confirmed
-	confirm := false
-	for !confirm {
-		confirm = true
-		for i := 0; i < len(client.neighbours); i = i + 1 {
-			if !client.neighbours[i].stopReply {
-				confirm = false
-			}
-		}
-		if !client.master.stopReply {
-			confirm = false
-		}
-	}
+	// Wait until all routines finish
+	client.stop()
 
 	// Call init function for reread config
 	client.init(c)
-	// Release the lock
-	// client.master.stopRequest = false
-	// for i := 0; i < len(client.neighbours); i = i + 1 {
-	// 	client.neighbours[i].stopRequest = false
-	// }
 	client.Run()
 	client.configuration.Logger.LogSystem("[Client] Client reloaded")
 }
@@ -549,27 +674,31 @@ func (client *ServerClient) Reload(c *AtellaConfig.Config) {
 // Function for stopping client
 func (client *ServerClient) Stop() {
 	client.configuration.Logger.LogSystem("[Client] Stopping client")
+	client.stop()
+	client.configuration.Logger.LogSystem("[Client] Client stopped")
+}
 
-	// Trying accuire the lock
-	close(client.stopRequest)
-	// client.master.stopRequest = true
-	// for i := 0; i < len(client.neighbours); i = i + 1 {
-	// 	client.neighbours[i].stopRequest = true
-	// }
-
-	// Wait until the lock is confirmed
-	confirm := false
-	for !confirm {
-		confirm = true
-		for i := 0; i < len(client.neighbours); i = i + 1 {
-			if !client.neighbours[i].stopReply {
-				confirm = false
-			}
+// Function cancel scheduled routines, wait until they finish and close
+// connections
+func (client *ServerClient) stop() {
+	client.scheduler.Stop()
+	for i := 0; i < len(client.neighbours); i = i + 1 {
+		c := &client.neighbours[i]
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		client.configuration.Logger.LogSystem(
+			fmt.Sprintf("Routine for %s:%d stopped", c.address, c.port))
+	}
+	for i := 0; i < len(client.masters); i = i + 1 {
+		if client.masters[i].conn != nil {
+			client.masters[i].conn.Close()
 		}
-		if !client.master.stopReply {
-			confirm = false
+	}
+	for i := 0; i < len(client.replicas); i = i + 1 {
+		if client.replicas[i].conn != nil {
+			client.replicas[i].conn.Close()
 		}
 	}
-
-	client.configuration.Logger.LogSystem("[Client] Client stopped")
+	client.configuration.Logger.LogSystem(fmt.Sprintf("Master client connection stoped"))
 }
@@ -0,0 +1,37 @@
+package AtellaClient
+
+import (
+	"context"
+	"fmt"
+
+	"../AtellaCheck"
+	"../AtellaConfig"
+)
+
+// Function prepare checks of local host from agent section. Local host
+// are checked like neighbour, so results and reports are the same
+func (c *ServerClient) initLocalChecks() {
+	c.local = neigbour{
+		address:  "127.0.0.1",
+		hostname: c.configuration.Agent.Hostname,
+		checks:   make([]*AtellaCheck.CheckConfig, 0),
+		results:  make([]AtellaCheck.Result, 0)}
+	for _, check := range c.configuration.Agent.LocalChecks {
+		c.local.checks = append(c.local.checks, check)
+		c.local.results = append(c.local.results, pendingResult(check))
+		c.configuration.Logger.LogInfo(fmt.Sprintf("Added local check %s [%s]",
+			check.GetName(), check.Type))
+	}
+}
+
+// Function schedule checks of local host every interval. Checks with own
+// interval are made when they are due
+func (c *ServerClient) runLocalChecks() {
+	if len(c.local.checks) < 1 {
+		return
+	}
+	c.scheduler.Every(AtellaConfig.Seconds(c.configuration.Agent.Interval),
+		func(ctx context.Context) {
+			c.runChecks(ctx, &c.local)
+		})
+}
@@ -0,0 +1,163 @@
+package AtellaClient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// Result of one neighbour probe
+type probeResult struct {
+	stage    string
+	reason   string
+	err      error
+	hostname string
+	latency  float64
+	dialTime float64
+	dialed   bool
+	info     *AtellaConfig.HostInfo
+}
+
+// Function return true if probe reached last stage
+func (r *probeResult) ok() bool {
+	return r.stage == AtellaConfig.StageDone
+}
+
+// Function make one probe of neighbour as state machine:
+// dial - hello - auth - hostname - host - info - done. Dial and hello are made
+// only if connection has error. Each stage has own deadline. Probe stops
+// on first failed stage and result keeps stage and reason of failure
+func (client *ServerClient) probe(ctx context.Context, c *neigbour) *probeResult {
+	var start time.Time
+	res := &probeResult{stage: AtellaConfig.StageAuth}
+	if c.connError {
+		res.stage = AtellaConfig.StageDial
+	}
+
+	for !res.ok() {
+		if res.stage == AtellaConfig.StageAuth {
+			start = time.Now()
+		}
+		next, err := client.probeStage(ctx, c, res)
+		if err != nil {
+			res.err = err
+			if res.reason == "" {
+				res.reason = probeReason(res.stage, err)
+			}
+			if c.conn != nil {
+				c.conn.Close()
+			}
+			c.connError = true
+			return res
+		}
+		res.stage = next
+	}
+	res.latency = float64(time.Since(start)) / float64(time.Millisecond)
+	return res
+}
+
+// Function run current stage of probe and return next stage
+func (client *ServerClient) probeStage(ctx context.Context, c *neigbour,
+	res *probeResult) (string, error) {
+	var err error
+	conf := client.configuration
+
+	if c.conn != nil && res.stage != AtellaConfig.StageDial {
+		c.conn.SetDeadline(time.Now().Add(AtellaConfig.Seconds(int64(conf.Agent.NetTimeout))))
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	switch res.stage {
+	case AtellaConfig.StageDial:
+		start := time.Now()
+		c.conn, err = conf.DialContext(ctx, c.address, c.port)
+		if err != nil {
+			c.conn = nil
+			return "", err
+		}
+		res.dialed = true
+		res.dialTime = float64(time.Since(start)) / float64(time.Millisecond)
+		return AtellaConfig.StageHello, nil
+
+	case AtellaConfig.StageHello:
+		c.session = newSession(c.conn, conf)
+		if err = c.session.Hello(); err != nil {
+			return "", err
+		}
+		c.connError = false
+		conf.Vector.Update(c.address, c.session.FillVector)
+		return AtellaConfig.StageAuth, nil
+
+	case AtellaConfig.StageAuth:
+		err = c.session.Auth()
+		if _, ok := err.(*replyError); ok {
+			res.reason = AtellaConfig.ReasonAuthRejected
+		}
+		if err != nil {
+			return "", err
+		}
+		return AtellaConfig.StageHostname, nil
+
+	case AtellaConfig.StageHostname:
+		res.hostname, err = c.session.GetHostname()
+		if err != nil {
+			return "", err
+		}
+		if c.hostname != "" && res.hostname != c.hostname {
+			res.reason = AtellaConfig.ReasonHostnameMismatch
+			return "", fmt.Errorf("Hostname mismatch [%s], expected [%s]",
+				res.hostname, c.hostname)
+		}
+		return AtellaConfig.StageHost, nil
+
+	case AtellaConfig.StageHost:
+		host, err := c.session.SetHost(conf.Agent.Hostname)
+		if err != nil {
+			return "", err
+		}
+		if host != conf.Agent.Hostname {
+			res.reason = AtellaConfig.ReasonHostMismatch
+			return "", fmt.Errorf("Host mismatch [%s]", host)
+		}
+		return AtellaConfig.StageInfo, nil
+
+	case AtellaConfig.StageInfo:
+		// Old agents doesn.t answer unknown commands
+		if !c.session.HasCapability(AtellaConfig.CapHostInfo) {
+			return AtellaConfig.StageDone, nil
+		}
+		res.info, err = c.session.GetInfo()
+		if err != nil {
+			return "", err
+		}
+		return AtellaConfig.StageDone, nil
+	}
+	return "", fmt.Errorf("Unknown probe stage %s", res.stage)
+}
+
+// Function classify error of probe stage
+func probeReason(stage string, err error) string {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return AtellaConfig.ReasonTimeout
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return AtellaConfig.ReasonTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return AtellaConfig.ReasonDialRefused
+	}
+	if stage == AtellaConfig.StageDial {
+		return AtellaConfig.ReasonDialFailed
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return AtellaConfig.ReasonConnClosed
+	}
+	return AtellaConfig.ReasonProtocolError
+}
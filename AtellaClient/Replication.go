@@ -0,0 +1,102 @@
+package AtellaClient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// Connection to other master for replication of master vector
+type replica struct {
+	conn      net.Conn
+	session   *session
+	connError bool
+	address   string
+}
+
+// Function fill replicas by master servers except me. Me is found by
+// hostname or local address. Only masters replicate master vector
+func (c *ServerClient) initReplicas() {
+	c.replicas = make([]replica, 0)
+	if !c.configuration.Agent.Master {
+		return
+	}
+	me := c.configuration.LocalMasterName()
+	for _, host := range c.configuration.MasterServers.Hosts {
+		entry := strings.Split(host, " ")
+		if entry[len(entry)-1] == me {
+			continue
+		}
+		c.replicas = append(c.replicas, replica{
+			conn:      nil,
+			session:   nil,
+			connError: true,
+			address:   entry[0]})
+	}
+}
+
+// Function schedule replication of master vector to other masters every
+// interval
+func (c *ServerClient) runReplication() {
+	for i := 0; i < len(c.replicas); i = i + 1 {
+		r := &c.replicas[i]
+		c.configuration.Logger.LogInfo(fmt.Sprintf(
+			"[Client] Start replication to master %s", r.address))
+		c.scheduler.Every(AtellaConfig.Seconds(c.configuration.Agent.Interval),
+			func(ctx context.Context) {
+				c.replicate(ctx, r)
+			})
+	}
+}
+
+// Function make one iteration of replication: reopen connection to master
+// if it has error, then send master vector entries
+func (c *ServerClient) replicate(ctx context.Context, r *replica) {
+	var err error = nil
+	if r.connError {
+		r.conn, err = c.configuration.DialContext(ctx, r.address, 5223)
+		if err != nil {
+			c.configuration.Logger.LogError(fmt.Sprintf(
+				"[Client] Replica [%s] - %s", r.address, err))
+			r.conn = nil
+			return
+		}
+		r.session = newSession(r.conn, c.configuration)
+		err = r.session.Hello()
+		if err == nil && !r.session.HasCapability(AtellaConfig.CapReplication) {
+			err = fmt.Errorf("Master doesn.t support replication")
+		}
+		if err != nil {
+			c.configuration.Logger.LogError(fmt.Sprintf(
+				"[Client] Replica [%s] hello - %s", r.address, err))
+			r.conn.Close()
+			return
+		}
+		r.connError = false
+	}
+
+	r.conn.SetDeadline(time.Now().Add(
+		time.Duration(c.configuration.Agent.NetTimeout) * time.Second))
+	defer r.conn.SetDeadline(time.Time{})
+
+	err = r.session.Auth()
+	if err == nil {
+		_, err = r.session.SetHost(c.configuration.Agent.Hostname)
+	}
+	if err == nil {
+		err = r.session.SetMaster(c.configuration.GetMasterEntries())
+	}
+	if err != nil {
+		r.connError = true
+		r.conn.Close()
+		c.configuration.Logger.LogError(fmt.Sprintf(
+			"[Client] Replica [%s] - %s", r.address, err))
+		return
+	}
+	// Master, which accepted replication, is alive for election
+	c.configuration.MasterSeen(r.address)
+}
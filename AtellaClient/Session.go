@@ -0,0 +1,449 @@
+package AtellaClient
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// Protocol session with remote agent. Hides differences between text and
+// JSON protocols
+type session struct {
+	conn            net.Conn
+	reader          *bufio.Reader
+	configuration   *AtellaConfig.Config
+	protocol        string
+	challenge       bool
+	peer            *AtellaConfig.Hello
+	capabilities    []string
+	missing         []string
+	emptyMessageCnt uint64
+}
+
+// Error, returned by remote agent
+type replyError struct {
+	reason string
+}
+
+func (e *replyError) Error() string {
+	return fmt.Sprintf("Receive %s %s", errMsg, e.reason)
+}
+
+// Function create session on opened connection. Until hello protocol are
+// text, or json if it forced by config
+func newSession(conn net.Conn, configuration *AtellaConfig.Config) *session {
+	protocol := configuration.Agent.Protocol
+	if protocol != AtellaConfig.ProtocolJson {
+		protocol = AtellaConfig.ProtocolText
+	}
+	return &session{
+		conn:            conn,
+		reader:          bufio.NewReader(conn),
+		configuration:   configuration,
+		protocol:        protocol,
+		challenge:       configuration.UseChallenge(),
+		peer:            AtellaConfig.GetLegacyHello(),
+		capabilities:    make([]string, 0),
+		missing:         make([]string, 0),
+		emptyMessageCnt: 0}
+}
+
+// Function exchange hello with remote agent and select protocol and auth
+// by common capabilities. Agent, which doesn.t answer or rejects hello,
+// are legacy. Hello isn.t sent to agents, which were recently legacy
+func (s *session) Hello() error {
+	var peer AtellaConfig.Hello
+
+	_, encrypted := s.conn.(*tls.Conn)
+	local := s.configuration.GetHello(encrypted)
+	address := s.conn.RemoteAddr().String()
+	if s.configuration.IsLegacyPeer(address) {
+		s.setPeer(local, AtellaConfig.GetLegacyHello())
+		return nil
+	}
+	js, err := json.Marshal(local)
+	if err != nil {
+		return err
+	}
+
+	s.conn.SetDeadline(time.Now().Add(
+		time.Duration(s.configuration.Agent.NetTimeout) * time.Second))
+	defer s.conn.SetDeadline(time.Time{})
+
+	if err = s.send(fmt.Sprintf("hello %s\n", js)); err != nil {
+		return err
+	}
+	msg, err := s.readLine()
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// Slow agent may be not legacy, so auth is not downgraded here:
+		// challenge are requested anyway and legacy agent rejects it
+		s.configuration.SetLegacyPeer(address)
+		s.setPeer(local, AtellaConfig.GetLegacyHello())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	msgMap := strings.SplitN(msg, " ", 4)
+	if msgMap[0] == errMsg {
+		// Agents, which require auth before any command, reject hello
+		s.configuration.SetLegacyPeer(address)
+		s.setPeer(local, AtellaConfig.GetLegacyHello())
+		return nil
+	}
+	if msgMap[0] != okMsg || len(msgMap) < 4 || msgMap[1] != "ack" ||
+		msgMap[2] != "hello" {
+		return fmt.Errorf("Unexpected reply [%s]", msg)
+	}
+	if err = json.Unmarshal([]byte(msgMap[3]), &peer); err != nil {
+		return fmt.Errorf("Hello - %s", err)
+	}
+	s.setPeer(local, &peer)
+	return nil
+}
+
+// Function save hello of remote agent and select features
+func (s *session) setPeer(local *AtellaConfig.Hello, peer *AtellaConfig.Hello) {
+	conf := s.configuration
+	s.peer = peer
+	s.capabilities = AtellaConfig.CommonCapabilities(local.Capabilities,
+		peer.Capabilities)
+	s.missing = AtellaConfig.MissingCapabilities(local.Capabilities,
+		peer.Capabilities)
+
+	switch conf.Agent.Protocol {
+	case AtellaConfig.ProtocolJson, AtellaConfig.ProtocolText:
+		s.protocol = conf.Agent.Protocol
+	default:
+		s.protocol = AtellaConfig.ProtocolText
+		if AtellaConfig.HasCapability(s.capabilities, AtellaConfig.CapJsonFrames) {
+			s.protocol = AtellaConfig.ProtocolJson
+		}
+	}
+
+	if peer.ProtocolVersion != local.ProtocolVersion || len(s.missing) > 0 {
+		conf.Logger.LogWarning(fmt.Sprintf(
+			"[Client] Agent %s version %s, protocol %d, missing capabilities [%s]",
+			s.conn.RemoteAddr(), peer.Version, peer.ProtocolVersion,
+			strings.Join(s.missing, ", ")))
+	}
+	conf.Logger.LogInfo(fmt.Sprintf(
+		"[Client] Agent %s speaks %s protocol, common capabilities [%s]",
+		s.conn.RemoteAddr(), s.protocol, strings.Join(s.capabilities, ", ")))
+}
+
+// Function fill vector element by hello of remote agent
+func (s *session) FillVector(vec *AtellaConfig.VectorType) {
+	vec.Version = s.peer.Version
+	vec.ProtocolVersion = s.peer.ProtocolVersion
+	vec.Capabilities = s.peer.Capabilities
+	vec.Missing = s.missing
+}
+
+// Function send string via connection
+func (s *session) send(message string) error {
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+// Function send JSON frame via connection
+func (s *session) sendFrame(req *AtellaConfig.Request) error {
+	req.Version = AtellaConfig.ProtocolVersion
+	b, err := AtellaConfig.EncodeFrame(req)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(b)
+	return err
+}
+
+// Function read one non-empty line from connection
+func (s *session) readLine() (string, error) {
+	for {
+		message, err := s.reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		msg := strings.TrimRight(message, "\r\n")
+		if msg != "" {
+			s.emptyMessageCnt = 0
+			return msg, nil
+		}
+		s.emptyMessageCnt = s.emptyMessageCnt + 1
+		if s.emptyMessageCnt > 5 {
+			return "", fmt.Errorf("Received %d empty messages", s.emptyMessageCnt)
+		}
+	}
+}
+
+// Function read text reply and check, that it is ack of expected command
+func (s *session) readText(ack string) ([]string, error) {
+	msg, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	msgMap := strings.Split(msg, " ")
+	if msgMap[0] == errMsg {
+		return msgMap, &replyError{reason: strings.Join(msgMap[1:], " ")}
+	}
+	if msgMap[0] != okMsg || len(msgMap) < 3 || msgMap[1] != "ack" ||
+		msgMap[2] != ack {
+		return msgMap, fmt.Errorf("Unexpected reply [%s]", msg)
+	}
+	return msgMap, nil
+}
+
+// Function read JSON response and check, that it is response of expected
+// command
+func (s *session) readFrame(cmd string) (*AtellaConfig.Response, error) {
+	var res AtellaConfig.Response
+	msg, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(msg), &res)
+	if err != nil {
+		return nil, fmt.Errorf("Bad frame - %s", err)
+	}
+	if res.Status != AtellaConfig.StatusOk {
+		return &res, &replyError{reason: res.Error}
+	}
+	if res.Cmd != cmd {
+		return &res, fmt.Errorf("Unexpected response of %s", res.Cmd)
+	}
+	return &res, nil
+}
+
+// Function authenticate session by challenge-response or by code phrase
+func (s *session) Auth() error {
+	if s.protocol == AtellaConfig.ProtocolJson {
+		return s.authJson()
+	}
+	return s.authText()
+}
+
+func (s *session) authText() error {
+	conf := s.configuration
+	if !s.challenge {
+		if err := s.send(conf.GetPlainAuthRequest()); err != nil {
+			return err
+		}
+		_, err := s.readText("auth")
+		return err
+	}
+
+	if err := s.send(conf.GetAuthRequest()); err != nil {
+		return err
+	}
+	msgMap, err := s.readText("challenge")
+	if _, ok := err.(*replyError); ok && conf.PlainFallbackAllowed() {
+		// Old agent doesn.t support challenge, fallback to code
+		conf.Logger.LogSystem(fmt.Sprintf(
+			"[Client] Security - agent %s rejected challenge, code phrase are sent",
+			s.conn.RemoteAddr()))
+		if err = s.send(conf.GetPlainAuthRequest()); err != nil {
+			return err
+		}
+		_, err = s.readText("auth")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if len(msgMap) < 4 {
+		return fmt.Errorf("Msg len expected ack < 4")
+	}
+	if err = s.send(conf.GetChallengeResponse(msgMap[3])); err != nil {
+		return err
+	}
+	_, err = s.readText("auth")
+	return err
+}
+
+func (s *session) authJson() error {
+	conf := s.configuration
+	if !s.challenge {
+		err := s.sendFrame(&AtellaConfig.Request{
+			Cmd:  "auth",
+			Code: conf.Security.Code})
+		if err != nil {
+			return err
+		}
+		_, err = s.readFrame("auth")
+		return err
+	}
+
+	err := s.sendFrame(&AtellaConfig.Request{Cmd: "auth_challenge"})
+	if err != nil {
+		return err
+	}
+	res, err := s.readFrame("auth_challenge")
+	if err != nil {
+		return err
+	}
+	err = s.sendFrame(&AtellaConfig.Request{
+		Cmd:      "auth_hmac",
+		Hostname: conf.Agent.Hostname,
+		Digest: AtellaConfig.GetAuthDigest(conf.Security.Code, res.Nonce,
+			conf.Agent.Hostname)})
+	if err != nil {
+		return err
+	}
+	_, err = s.readFrame("auth_hmac")
+	return err
+}
+
+// Function request hostname of remote agent
+func (s *session) GetHostname() (string, error) {
+	if s.protocol == AtellaConfig.ProtocolJson {
+		if err := s.sendFrame(&AtellaConfig.Request{Cmd: "get_hostname"}); err != nil {
+			return "", err
+		}
+		res, err := s.readFrame("get_hostname")
+		if err != nil {
+			return "", err
+		}
+		return res.Value, nil
+	}
+
+	if err := s.send("get hostname\n"); err != nil {
+		return "", err
+	}
+	msgMap, err := s.readText("hostname")
+	if err != nil {
+		return "", err
+	}
+	if len(msgMap) < 4 {
+		return "", fmt.Errorf("Msg len expected ack < 4")
+	}
+	return msgMap[3], nil
+}
+
+// Function request local health of remote agent
+func (s *session) GetInfo() (*AtellaConfig.HostInfo, error) {
+	var info AtellaConfig.HostInfo
+	if s.protocol == AtellaConfig.ProtocolJson {
+		if err := s.sendFrame(&AtellaConfig.Request{Cmd: "get_info"}); err != nil {
+			return nil, err
+		}
+		res, err := s.readFrame("get_info")
+		if err != nil {
+			return nil, err
+		}
+		if res.Info == nil {
+			return nil, fmt.Errorf("Info are not received")
+		}
+		return res.Info, nil
+	}
+
+	if err := s.send("get info\n"); err != nil {
+		return nil, err
+	}
+	msg, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	// Info json may contain spaces, so it is the rest of message
+	msgMap := strings.SplitN(msg, " ", 4)
+	if msgMap[0] == errMsg {
+		return nil, &replyError{reason: strings.Join(msgMap[1:], " ")}
+	}
+	if msgMap[0] != okMsg || len(msgMap) < 4 || msgMap[1] != "ack" ||
+		msgMap[2] != "info" {
+		return nil, fmt.Errorf("Unexpected reply [%s]", msg)
+	}
+	if err = json.Unmarshal([]byte(msgMap[3]), &info); err != nil {
+		return nil, fmt.Errorf("Info - %s", err)
+	}
+	return &info, nil
+}
+
+// Function return true if remote agent supports capability
+func (s *session) HasCapability(c string) bool {
+	return AtellaConfig.HasCapability(s.capabilities, c)
+}
+
+// Function introduce local host to remote agent. Return host, which
+// remote agent saved
+func (s *session) SetHost(host string) (string, error) {
+	if s.protocol == AtellaConfig.ProtocolJson {
+		err := s.sendFrame(&AtellaConfig.Request{
+			Cmd:  "set_host",
+			Host: host})
+		if err != nil {
+			return "", err
+		}
+		res, err := s.readFrame("set_host")
+		if err != nil {
+			return "", err
+		}
+		return res.Value, nil
+	}
+
+	if err := s.send(fmt.Sprintf("set host %s\n", host)); err != nil {
+		return "", err
+	}
+	msgMap, err := s.readText("host")
+	if err != nil {
+		return "", err
+	}
+	if len(msgMap) < 4 {
+		return "", fmt.Errorf("Msg len expected ack < 4")
+	}
+	return msgMap[3], nil
+}
+
+// Function send master vector entries to remote master
+func (s *session) SetMaster(entries map[string]AtellaConfig.MasterEntry) error {
+	if s.protocol == AtellaConfig.ProtocolJson {
+		err := s.sendFrame(&AtellaConfig.Request{
+			Cmd:    "set_master",
+			Master: entries})
+		if err != nil {
+			return err
+		}
+		_, err = s.readFrame("set_master")
+		return err
+	}
+
+	js, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err = s.send(fmt.Sprintf("set master %s\n", js)); err != nil {
+		return err
+	}
+	_, err = s.readText("set")
+	return err
+}
+
+// Function send vector of host to remote agent
+func (s *session) SetVector(host string, vector []AtellaConfig.VectorType) error {
+	if s.protocol == AtellaConfig.ProtocolJson {
+		err := s.sendFrame(&AtellaConfig.Request{
+			Cmd:    "set_vector",
+			Host:   host,
+			Vector: vector})
+		if err != nil {
+			return err
+		}
+		_, err = s.readFrame("set_vector")
+		return err
+	}
+
+	js, err := json.Marshal(vector)
+	if err != nil {
+		return err
+	}
+	if err = s.send(fmt.Sprintf("set vector %s %s\n", host, js)); err != nil {
+		return err
+	}
+	_, err = s.readText("set")
+	return err
+}
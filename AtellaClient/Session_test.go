@@ -0,0 +1,161 @@
+package AtellaClient
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// Function serve fake remote agent on connection: every received line is
+// recorded and answered by reply. Received lines are returned after
+// connection closed
+func servePeer(conn net.Conn, reply func(line string) string) chan []string {
+	res := make(chan []string, 1)
+	go func() {
+		lines := make([]string, 0)
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\n")
+			lines = append(lines, line)
+			if answer := reply(line); answer != "" {
+				conn.Write([]byte(answer + "\n"))
+			}
+		}
+		conn.Close()
+		res <- lines
+	}()
+	return res
+}
+
+// Answers of legacy agent, which knows only code phrase
+func legacyPeer(line string) string {
+	if line == "auth CodePhrase" {
+		return fmt.Sprintf("%s ack auth", okMsg)
+	}
+	return fmt.Sprintf("%s Unknown command", errMsg)
+}
+
+// Answers of agent, which supports challenge
+func challengePeer(line string) string {
+	if line == "auth challenge" {
+		return fmt.Sprintf("%s ack challenge nonce", okMsg)
+	}
+	if strings.HasPrefix(line, "auth hmac ") {
+		fields := strings.Split(line, " ")
+		if len(fields) == 4 && AtellaConfig.CheckAuthDigest("CodePhrase",
+			"nonce", fields[2], fields[3]) {
+			return fmt.Sprintf("%s ack auth", okMsg)
+		}
+	}
+	return fmt.Sprintf("%s Auth failed", errMsg)
+}
+
+func TestAuthText(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		fallback bool
+		peer     func(string) string
+		ok       bool
+		codeSent bool
+	}{
+		{"plain", AtellaConfig.AuthModePlain, false, legacyPeer, true, true},
+		{"hmac", AtellaConfig.AuthModeHmac, false, challengePeer, true, false},
+		{"compat with new agent", AtellaConfig.AuthModeCompat, true,
+			challengePeer, true, false},
+		{"compat without fallback", AtellaConfig.AuthModeCompat, false,
+			legacyPeer, false, false},
+		{"compat with fallback", AtellaConfig.AuthModeCompat, true, legacyPeer,
+			true, true},
+		{"hmac ignores fallback", AtellaConfig.AuthModeHmac, true, legacyPeer,
+			false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := AtellaConfig.NewConfig()
+			conf.Agent.Hostname = "client"
+			conf.Agent.Protocol = AtellaConfig.ProtocolText
+			conf.Security.AuthMode = tt.mode
+			conf.Security.AllowPlainFallback = tt.fallback
+			local, remote := net.Pipe()
+			lines := servePeer(remote, tt.peer)
+
+			err := newSession(local, conf).Auth()
+			local.Close()
+			received := <-lines
+			if (err == nil) != tt.ok {
+				t.Errorf("Auth() error = %v, want success %v", err, tt.ok)
+			}
+			codeSent := false
+			for _, line := range received {
+				if strings.Contains(line, "CodePhrase") {
+					codeSent = true
+				}
+			}
+			if codeSent != tt.codeSent {
+				t.Errorf("code phrase sent = %v, want %v, received %q", codeSent,
+					tt.codeSent, received)
+			}
+		})
+	}
+}
+
+func TestHelloLegacyPeer(t *testing.T) {
+	conf := AtellaConfig.NewConfig()
+	conf.Security.AuthMode = AtellaConfig.AuthModeCompat
+	local, remote := net.Pipe()
+	lines := servePeer(remote, legacyPeer)
+
+	s := newSession(local, conf)
+	err := s.Hello()
+	local.Close()
+	<-lines
+	if err != nil {
+		t.Fatalf("Hello() error = %s", err)
+	}
+	if s.peer.ProtocolVersion != AtellaConfig.GetLegacyHello().ProtocolVersion {
+		t.Errorf("peer = %+v, want legacy", s.peer)
+	}
+	if !s.challenge || s.protocol != AtellaConfig.ProtocolText {
+		t.Errorf("challenge = %v, protocol = %s, want challenge in text",
+			s.challenge, s.protocol)
+	}
+}
+
+func TestHelloLegacyCache(t *testing.T) {
+	conf := AtellaConfig.NewConfig()
+	conf.Agent.NetTimeout = 1
+	silent := func(line string) string { return "" }
+
+	for i, wantHello := range []bool{true, false} {
+		local, remote := net.Pipe()
+		lines := servePeer(remote, silent)
+		start := time.Now()
+		err := newSession(local, conf).Hello()
+		elapsed := time.Since(start)
+		local.Close()
+		received := <-lines
+		if err != nil {
+			t.Fatalf("Hello() %d error = %s", i, err)
+		}
+		if (len(received) > 0) != wantHello {
+			t.Errorf("Hello() %d received %q, want hello sent %v", i, received,
+				wantHello)
+		}
+		if !wantHello && elapsed > 500*time.Millisecond {
+			t.Errorf("Hello() %d to cached legacy peer took %s", i, elapsed)
+		}
+	}
+	if !conf.IsLegacyPeer("pipe") {
+		t.Error("IsLegacyPeer() = false after hello timeout")
+	}
+}
@@ -2,6 +2,7 @@ package AtellaConfig
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,6 +16,8 @@ import (
 	"sync"
 	"syscall"
 
+	"../AtellaCheck"
+	"../AtellaGraphiteChannel"
 	"../AtellaLogger"
 	"../AtellaMailChannel"
 	"../AtellaTgSibnetChannel"
@@ -28,7 +31,36 @@ type VectorType struct {
 	Status    bool     `json:"status"`
 	Interval  int64    `json:"interval"`
 	Timestamp int64    `json:"timestamp"`
+	Latency   float64  `json:"latency"`
+	LastSeen  int64    `json:"last_seen"`
 	Sectors   []string `json:"sectors"`
+	// Time of connection opening and handshake round-trip time (auth -
+	// hostname - host) over sliding window of probes
+	DialTime LatencyStats `json:"dial_time"`
+	Rtt      LatencyStats `json:"rtt"`
+	// Results of sector checks of host, made by neighbour
+	Checks []AtellaCheck.Result `json:"checks"`
+	// Local health of host, received from it, and problems by thresholds
+	Info     *HostInfo `json:"info,omitempty"`
+	Problems []Problem `json:"problems"`
+	// Result of last probe: stage, where probe stopped, reason and error
+	// if probe failed
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+	// Hysteresis: consecutive probe results, count of status changes and
+	// flapping flag. Changes are times of status changes in flap window
+	Successes         int64 `json:"consecutive_successes"`
+	Failures          int64 `json:"consecutive_failures"`
+	Transitions       int64 `json:"transitions"`
+	RecentTransitions int64 `json:"recent_transitions"`
+	Flapping          bool  `json:"flapping"`
+	changes           []int64
+	// Filled by hello of neighbour
+	Version         string   `json:"version"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+	Missing         []string `json:"missing"`
 }
 
 var (
@@ -56,6 +88,7 @@ type AtellaConfig struct {
 	LogFile      string `json:"log_file"`
 	PidFile      string `json:"pid_file"`
 	ProcFile     string `json:"proc_file"`
+	StateFile    string `json:"state_file"`
 	LogLevel     int64  `json:"log_level"`
 	HostCnt      int64  `json:"host_cnt"`
 	HexLen       int64  `json:"hex_len"`
@@ -63,10 +96,42 @@ type AtellaConfig struct {
 	Master       bool   `json:"master"`
 	Interval     int64  `json:"interval"`
 	NetTimeout   int    `json:"net_timeout"`
+	Quorum       int64  `json:"quorum"`
+	// Reporters, which didn.t send vector during reporter_expire
+	// intervals, are not counted in verdicts. 0 - never expire
+	ReporterExpire int64  `json:"reporter_expire"`
+	Protocol       string `json:"protocol"`
+	HttpAddress    string `json:"http_address"`
+	RiseCount      int64  `json:"rise_count"`
+	FailCount      int64  `json:"fail_count"`
+	FlapCount      int64  `json:"flap_count"`
+	FlapWindow     int64  `json:"flap_window"`
+	RttWindow      int64  `json:"rtt_window"`
+	// Agent reports neighbours status itself, if masters are not specified
+	LocalAlerts   bool  `json:"local_alerts"`
+	AlertCooldown int64 `json:"alert_cooldown"`
+	// Vector are sent to all masters instead of one with failover
+	MasterFanout bool `json:"master_fanout"`
+	// Master, which was not seen during lease seconds, can't be leader
+	LeaderLease int64 `json:"leader_lease"`
+	// Thresholds of host health, 0 - disabled
+	LoadThreshold   float64 `json:"load_threshold"`
+	MemoryThreshold float64 `json:"memory_threshold"`
+	DiskThreshold   float64 `json:"disk_threshold"`
+	// Checks of local host, made by agent itself
+	LocalChecks []*AtellaCheck.CheckConfig `json:"local_checks"`
 }
 
 type SecurityConfig struct {
-	Code string `json:"code"`
+	Code            string         `json:"code"`
+	Tokens          []*TokenConfig `json:"tokens"`
+	AuthMode        string         `json:"auth_mode"`
+	MaxAuthFailures int64          `json:"max_auth_failures"`
+	Cert            string         `json:"cert"`
+	Key             string         `json:"key"`
+	CA              string         `json:"ca"`
+	// Client sends code phrase to servers, which reject challenge
+	AllowPlainFallback bool `json:"allow_plain_fallback"`
 }
 
 type DatabaseConfig struct {
@@ -84,82 +149,112 @@ type SectorsConfig struct {
 }
 
 type MasterServersConfig struct {
-	Hosts []string `json:"hosts"`
+	Hosts      []string                `json:"hosts"`
+	Priorities []*MasterPriorityConfig `json:"priorities"`
+	// Seconds between attempts to return to preferred master
+	RebalanceInterval int64 `json:"rebalance_interval"`
+}
+
+// Priority of master. Host is address or hostname of master_servers entry.
+// Master with higher priority is preferred, default priority is 0
+type MasterPriorityConfig struct {
+	Host     string `json:"host"`
+	Priority int64  `json:"priority"`
 }
 
 type SectorConfig struct {
-	Hosts []string `json:"hosts"`
+	Hosts  []string                   `json:"hosts"`
+	Checks []*AtellaCheck.CheckConfig `json:"checks"`
 }
 
 type reporter struct {
-	mux         sync.Mutex
-	isLocked    bool
-	stopRequest bool
-	stopReply   bool
+	mux       sync.Mutex
+	isLocked  bool
+	scheduler *Scheduler
+	statsMux  sync.Mutex
+	stats     map[string]ChannelStats
 }
 
 type Config struct {
-	Agent                    *AtellaConfig              `json:"AgentSection"`
-	Security                 *SecurityConfig            `json:"SecuritySection"`
-	Channels                 map[string]*ChannelsConfig `json:"ChannelsSection"`
-	Sectors                  []*SectorsConfig           `json:"SectorsSection"`
-	DB                       *DatabaseConfig            `json:"DatabaseSection"`
-	MasterServers            *MasterServersConfig       `json:"MasterServersSection"`
-	reporter                 reporter
-	Logger                   *AtellaLogger.AtellaLogger
-	Pid                      int
-	Vector                   []VectorType
-	MasterVector             map[string][]VectorType
-	MasterVectorMutex        sync.RWMutex
-	CurrentMasterServerIndex int
+	Agent             *AtellaConfig              `json:"AgentSection"`
+	Security          *SecurityConfig            `json:"SecuritySection"`
+	Channels          map[string]*ChannelsConfig `json:"ChannelsSection"`
+	Sectors           []*SectorsConfig           `json:"SectorsSection"`
+	DB                *DatabaseConfig            `json:"DatabaseSection"`
+	MasterServers     *MasterServersConfig       `json:"MasterServersSection"`
+	reporter          reporter
+	election          election
+	legacy            legacyPeers
+	delivery          delivery
+	tls               tlsFiles
+	Logger            *AtellaLogger.AtellaLogger
+	Pid               int
+	Vector            *VectorStore
+	MasterVector      map[string][]VectorType
+	MasterTimestamps  map[string]int64
+	MasterVectorMutex sync.RWMutex
 }
 
 func NewConfig() *Config {
 	local := &Config{
 		Agent: &AtellaConfig{
-			Hostname:     "",
-			OmitHostname: false,
-			LogFile:      "/var/log/atella/atella.log",
-			PidFile:      "/usr/share/atella/atella.pid",
-			ProcFile:     "/usr/share/atella/atella.proc",
-			LogLevel:     2,
-			HostCnt:      1,
-			HexLen:       10,
-			MessagePath:  "/usr/share/atella/msg",
-			Master:       false,
-			Interval:     10,
-			NetTimeout:   2},
+			Hostname:        "",
+			OmitHostname:    false,
+			LogFile:         "/var/log/atella/atella.log",
+			PidFile:         "/usr/share/atella/atella.pid",
+			ProcFile:        "/usr/share/atella/atella.proc",
+			StateFile:       "/usr/share/atella/atella.state",
+			LogLevel:        2,
+			HostCnt:         1,
+			HexLen:          10,
+			MessagePath:     "/usr/share/atella/msg",
+			Master:          false,
+			Interval:        10,
+			NetTimeout:      2,
+			Quorum:          50,
+			ReporterExpire:  3,
+			Protocol:        ProtocolAuto,
+			HttpAddress:     "",
+			RiseCount:       2,
+			FailCount:       3,
+			FlapCount:       4,
+			FlapWindow:      600,
+			RttWindow:       10,
+			LocalAlerts:     false,
+			AlertCooldown:   300,
+			MasterFanout:    false,
+			LeaderLease:     30,
+			LoadThreshold:   0,
+			MemoryThreshold: 95,
+			DiskThreshold:   90,
+			LocalChecks:     make([]*AtellaCheck.CheckConfig, 0)},
 		Security: &SecurityConfig{
-			Code: "CodePhrase"},
+			Code:            "CodePhrase",
+			Tokens:          make([]*TokenConfig, 0),
+			AuthMode:        AuthModeCompat,
+			MaxAuthFailures: 3,
+			Cert:            "",
+			Key:             "",
+			CA:              ""},
 		DB: &DatabaseConfig{},
 		MasterServers: &MasterServersConfig{
-			Hosts: make([]string, 0)},
-		Channels:                 make(map[string]*ChannelsConfig),
-		Sectors:                  make([]*SectorsConfig, 0),
-		Logger:                   AtellaLogger.New(4, "stderr"),
-		Pid:                      0,
-		Vector:                   make([]VectorType, 0),
-		MasterVector:             make(map[string][]VectorType, 0),
-		MasterVectorMutex:        sync.RWMutex{},
-		CurrentMasterServerIndex: 0}
-
-	local.reporter.stopReply = false
-	local.reporter.stopRequest = false
+			Hosts:             make([]string, 0),
+			Priorities:        make([]*MasterPriorityConfig, 0),
+			RebalanceInterval: 300},
+		Channels:          make(map[string]*ChannelsConfig),
+		Sectors:           make([]*SectorsConfig, 0),
+		Logger:            AtellaLogger.New(4, "stderr"),
+		Pid:               0,
+		Vector:            NewVectorStore(),
+		MasterVector:      make(map[string][]VectorType, 0),
+		MasterTimestamps:  make(map[string]int64, 0),
+		MasterVectorMutex: sync.RWMutex{}}
+
+	local.reporter.scheduler = NewScheduler(context.Background())
 	local.reporter.isLocked = false
 	return local
 }
 
-// Function retur vector element in vector array if element exist.
-// Else return nil
-func (c *Config) GetVectorByHost(host string) (*VectorType, int) {
-	for i := 0; i < len(c.Vector); i = i + 1 {
-		if c.Vector[i].Host == host {
-			return &c.Vector[i], i
-		}
-	}
-	return nil, -1
-}
-
 // Function save procces ID to file, specifyied as pidFilePath.
 func (c *Config) SavePid() {
 	var err error
@@ -297,6 +392,37 @@ func (c *Config) GetJsonConfig() []byte {
 	return config_json
 }
 
+// Function return Config as json format with secrets replaced by "***"
+func (c *Config) GetJsonRedactedConfig() []byte {
+	var config interface{}
+	json.Unmarshal(c.GetJsonConfig(), &config)
+	config_json, err := json.Marshal(redact(config))
+	if err != nil {
+		c.Logger.LogSystem(fmt.Sprintf("Json encoding conig - %s", err))
+	}
+	return config_json
+}
+
+// Function replace values of secret keys in decoded json
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			switch key {
+			case "code", "token", "password":
+				v[key] = "***"
+			default:
+				v[key] = redact(val)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return value
+}
+
 // Function print Vector as json format
 func (c *Config) PrintJsonVector() {
 	res := c.GetJsonVector()
@@ -305,7 +431,7 @@ func (c *Config) PrintJsonVector() {
 
 // Function return Vector as json format
 func (c *Config) GetJsonVector() []byte {
-	res, _ := json.Marshal(c.Vector)
+	res, _ := json.Marshal(c.Vector.Snapshot())
 	return res
 }
 
@@ -351,6 +477,15 @@ func (c *Config) addChannel(name string, table *ast.Table) error {
 			Disabled:   false,
 			NetTimeout: c.Agent.NetTimeout}
 
+	case "Graphite":
+		rp.Config = &AtellaGraphiteChannel.AtellaGraphiteConfig{
+			Address:    "localhost",
+			Port:       2003,
+			Protocol:   "tcp",
+			Prefix:     "atella",
+			Disabled:   false,
+			NetTimeout: c.Agent.NetTimeout}
+
 	default:
 		rp.Channel = name
 		rp.Config = nil
@@ -370,7 +505,8 @@ func (c *Config) addSector(name string, table *ast.Table) error {
 	rp := &SectorsConfig{
 		Sector: name,
 		Config: &SectorConfig{
-			Hosts: []string{}}}
+			Hosts:  []string{},
+			Checks: make([]*AtellaCheck.CheckConfig, 0)}}
 
 	if err := toml.UnmarshalTable(table, rp.Config); err != nil {
 		return fmt.Errorf("Error parsing %s", err)
@@ -0,0 +1,46 @@
+package AtellaConfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"code", `{"code":"secret","hostname":"h"}`,
+			`{"code":"***","hostname":"h"}`},
+		{"nested", `{"channels":{"mail":{"password":"p","user":"u"}}}`,
+			`{"channels":{"mail":{"password":"***","user":"u"}}}`},
+		{"array", `{"tokens":[{"name":"n","token":"t"}]}`,
+			`{"tokens":[{"name":"n","token":"***"}]}`},
+		{"plain value", `"code"`, `"code"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.in), &value); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(redact(value))
+			if string(got) != tt.want {
+				t.Errorf("redact() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetJsonRedactedConfig(t *testing.T) {
+	c := NewConfig()
+	c.Security.Code = "very-secret-code"
+	c.Security.Tokens = []*TokenConfig{
+		{Name: "reader", Token: "very-secret-token", Role: RoleRead}}
+	res := string(c.GetJsonRedactedConfig())
+	if strings.Contains(res, "very-secret") {
+		t.Errorf("GetJsonRedactedConfig() leaks secret: %s", res)
+	}
+}
@@ -0,0 +1,144 @@
+package AtellaConfig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// Only code phrase is sent. Challenge is not requested
+	AuthModePlain string = "plain"
+	// Only challenge-response authentication is accepted
+	AuthModeHmac string = "hmac"
+	// Challenge is requested, but code phrase are accepted from old agents.
+	// Code phrase are sent to old servers only if allow_plain_fallback set
+	AuthModeCompat string = "compat"
+)
+
+// Function return HMAC(code, nonce||hostname) as hex string
+func GetAuthDigest(code string, nonce string, hostname string) string {
+	mac := hmac.New(sha256.New, []byte(code))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte(hostname))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function check digest, received as answer to challenge
+func CheckAuthDigest(code string, nonce string, hostname string,
+	digest string) bool {
+	expected := GetAuthDigest(code, nonce, hostname)
+	return hmac.Equal([]byte(expected), []byte(digest))
+}
+
+// Function check code phrase, received in plain auth
+func CheckAuthCode(code string, received string) bool {
+	return subtle.ConstantTimeCompare([]byte(code), []byte(received)) == 1
+}
+
+// Function return true if challenge must be requested by client
+func (c *Config) UseChallenge() bool {
+	return c.Security.AuthMode != AuthModePlain
+}
+
+// Function return true if code phrase may be sent or accepted
+func (c *Config) AllowPlainAuth() bool {
+	return c.Security.AuthMode != AuthModeHmac
+}
+
+// Function return true if client may send code phrase to server, which
+// rejected challenge. Fallback must be allowed explicitly in compat mode
+func (c *Config) PlainFallbackAllowed() bool {
+	return c.Security.AuthMode == AuthModeCompat && c.Security.AllowPlainFallback
+}
+
+// Function return first auth command for client side
+func (c *Config) GetAuthRequest() string {
+	if c.UseChallenge() {
+		return "auth challenge\n"
+	}
+	return c.GetPlainAuthRequest()
+}
+
+// Function return auth command with code phrase
+func (c *Config) GetPlainAuthRequest() string {
+	return fmt.Sprintf("auth %s\n", c.Security.Code)
+}
+
+// Function return answer to the challenge, received from server
+func (c *Config) GetChallengeResponse(nonce string) string {
+	return fmt.Sprintf("auth hmac %s %s\n", c.Agent.Hostname,
+		GetAuthDigest(c.Security.Code, nonce, c.Agent.Hostname))
+}
+
+const (
+	// May use export and get commands
+	RoleRead string = "read"
+	// May use read commands and set host, set vector
+	RoleAgent string = "agent"
+	// May use any commands. Code phrase has this role
+	RoleAdmin string = "admin"
+)
+
+var (
+	roleLevels = map[string]int{
+		RoleRead:  1,
+		RoleAgent: 2,
+		RoleAdmin: 3}
+)
+
+// Named access token with role
+type TokenConfig struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// Function return true if role is known
+func IsRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// Function return true if role grants access to commands of required role
+func RoleAllows(role string, required string) bool {
+	level, ok := roleLevels[role]
+	if !ok {
+		return false
+	}
+	return level >= roleLevels[required]
+}
+
+// Function return name and role of received code phrase or token.
+// If nothing matches return empty strings
+func (c *Config) GetCodeRole(received string) (string, string) {
+	if received == "" {
+		return "", ""
+	}
+	if CheckAuthCode(c.Security.Code, received) {
+		return "code", RoleAdmin
+	}
+	for _, t := range c.Security.Tokens {
+		if t.Token != "" && CheckAuthCode(t.Token, received) {
+			return t.Name, t.Role
+		}
+	}
+	return "", ""
+}
+
+// Function return name and role of secret, used for digest.
+// If nothing matches return empty strings
+func (c *Config) GetDigestRole(nonce string, hostname string,
+	digest string) (string, string) {
+	if CheckAuthDigest(c.Security.Code, nonce, hostname, digest) {
+		return "code", RoleAdmin
+	}
+	for _, t := range c.Security.Tokens {
+		if t.Token != "" && CheckAuthDigest(t.Token, nonce, hostname, digest) {
+			return t.Name, t.Role
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,129 @@
+package AtellaConfig
+
+import (
+	"testing"
+)
+
+func TestCheckAuthDigest(t *testing.T) {
+	digest := GetAuthDigest("secret", "nonce", "host")
+	tests := []struct {
+		name     string
+		code     string
+		nonce    string
+		hostname string
+		digest   string
+		ok       bool
+	}{
+		{"valid", "secret", "nonce", "host", digest, true},
+		{"wrong code", "other", "nonce", "host", digest, false},
+		{"wrong nonce", "secret", "replayed", "host", digest, false},
+		{"wrong hostname", "secret", "nonce", "other", digest, false},
+		{"empty digest", "secret", "nonce", "host", "", false},
+		{"truncated digest", "secret", "nonce", "host", digest[:10], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckAuthDigest(tt.code, tt.nonce, tt.hostname,
+				tt.digest); got != tt.ok {
+				t.Errorf("CheckAuthDigest() = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+	if GetAuthDigest("secret", "nonce", "host") != digest {
+		t.Errorf("GetAuthDigest() is not stable")
+	}
+	if len(digest) != 64 {
+		t.Errorf("GetAuthDigest() length = %d, want 64", len(digest))
+	}
+}
+
+func TestAuthModes(t *testing.T) {
+	tests := []struct {
+		mode      string
+		fallback  bool
+		challenge bool
+		plain     bool
+		downgrade bool
+	}{
+		{AuthModePlain, false, false, true, false},
+		{AuthModeHmac, false, true, false, false},
+		{AuthModeHmac, true, true, false, false},
+		{AuthModeCompat, false, true, true, false},
+		{AuthModeCompat, true, true, true, true},
+	}
+	for _, tt := range tests {
+		c := NewConfig()
+		c.Security.AuthMode = tt.mode
+		c.Security.AllowPlainFallback = tt.fallback
+		if got := c.UseChallenge(); got != tt.challenge {
+			t.Errorf("%s: UseChallenge() = %v, want %v", tt.mode, got,
+				tt.challenge)
+		}
+		if got := c.AllowPlainAuth(); got != tt.plain {
+			t.Errorf("%s: AllowPlainAuth() = %v, want %v", tt.mode, got, tt.plain)
+		}
+		if got := c.PlainFallbackAllowed(); got != tt.downgrade {
+			t.Errorf("%s/%v: PlainFallbackAllowed() = %v, want %v", tt.mode,
+				tt.fallback, got, tt.downgrade)
+		}
+	}
+}
+
+func TestGetCodeRole(t *testing.T) {
+	c := NewConfig()
+	c.Security.Code = "admincode"
+	c.Security.Tokens = []*TokenConfig{
+		{Name: "reader", Token: "readtok", Role: RoleRead},
+		{Name: "empty", Token: "", Role: RoleAdmin}}
+	tests := []struct {
+		received string
+		name     string
+		role     string
+	}{
+		{"admincode", "code", RoleAdmin},
+		{"readtok", "reader", RoleRead},
+		{"", "", ""},
+		{"wrong", "", ""},
+	}
+	for _, tt := range tests {
+		name, role := c.GetCodeRole(tt.received)
+		if name != tt.name || role != tt.role {
+			t.Errorf("GetCodeRole(%q) = %q, %q, want %q, %q", tt.received, name,
+				role, tt.name, tt.role)
+		}
+	}
+
+	nonce := "nonce"
+	digest := GetAuthDigest("readtok", nonce, "host")
+	if name, role := c.GetDigestRole(nonce, "host", digest); name != "reader" ||
+		role != RoleRead {
+		t.Errorf("GetDigestRole() = %q, %q, want reader, read", name, role)
+	}
+	if name, role := c.GetDigestRole(nonce, "host",
+		GetAuthDigest("", nonce, "host")); name != "" || role != "" {
+		t.Errorf("GetDigestRole() with empty token = %q, %q, want nothing",
+			name, role)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		ok       bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleRead, true},
+		{RoleAgent, RoleAgent, true},
+		{RoleAgent, RoleAdmin, false},
+		{RoleRead, RoleAgent, false},
+		{"", RoleRead, false},
+		{"root", RoleRead, false},
+	}
+	for _, tt := range tests {
+		if got := RoleAllows(tt.role, tt.required); got != tt.ok {
+			t.Errorf("RoleAllows(%q, %q) = %v, want %v", tt.role, tt.required,
+				got, tt.ok)
+		}
+	}
+}
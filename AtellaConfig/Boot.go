@@ -0,0 +1,119 @@
+package AtellaConfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// State of agent, saved between runs. Timestamp is time of last save, it is
+// time of shutdown if agent stopped gracefully
+type BootState struct {
+	BootId    string  `json:"boot_id"`
+	Uptime    float64 `json:"uptime"`
+	Timestamp int64   `json:"timestamp"`
+	Stopped   bool    `json:"stopped"`
+}
+
+// Function read current boot id and uptime of host
+func currentBootState() (*BootState, error) {
+	info := &HostInfo{}
+	if err := readBootId(info); err != nil {
+		return nil, err
+	}
+	if err := readUptime(info); err != nil {
+		return nil, err
+	}
+	return &BootState{
+		BootId:    info.BootId,
+		Uptime:    info.Uptime,
+		Timestamp: time.Now().Unix(),
+		Stopped:   false}, nil
+}
+
+// Function read boot state, saved by previous run
+func (c *Config) loadBootState() (*BootState, error) {
+	data, err := ioutil.ReadFile(c.Agent.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	var state BootState
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("State file %s - %s", c.Agent.StateFile, err)
+	}
+	return &state, nil
+}
+
+// Function save current boot state. Stopped means graceful shutdown of agent
+func (c *Config) SaveBootState(stopped bool) {
+	state, err := currentBootState()
+	if err != nil {
+		c.Logger.LogWarning(fmt.Sprintf("[Boot] Can't read boot state - %s", err))
+		return
+	}
+	state.Stopped = stopped
+	data, err := json.Marshal(state)
+	if err != nil {
+		c.Logger.LogError(fmt.Sprintf("[Boot] %s", err))
+		return
+	}
+	// Write temporary file first, so state file is never truncated
+	tmpPath := fmt.Sprintf("%s.tmp", c.Agent.StateFile)
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err == nil {
+		err = os.Rename(tmpPath, c.Agent.StateFile)
+	}
+	if err != nil {
+		c.Logger.LogError(fmt.Sprintf("[Boot] Can't save state - %s", err))
+	}
+}
+
+// Function compare boot state of previous run with current one and report
+// about host reboot or restart of agent without reboot
+func (c *Config) DetectBoot() {
+	cur, err := currentBootState()
+	if err != nil {
+		c.Logger.LogWarning(fmt.Sprintf("[Boot] Can't read boot state - %s", err))
+		return
+	}
+	prev, err := c.loadBootState()
+	if os.IsNotExist(err) {
+		c.Logger.LogSystem("[Boot] State file not found, first start of agent")
+		c.SaveBootState(false)
+		return
+	} else if err != nil {
+		c.Logger.LogWarning(fmt.Sprintf("[Boot] %s", err))
+		c.SaveBootState(false)
+		return
+	}
+
+	shutdown := fmt.Sprintf("Previous shutdown at [%s]",
+		time.Unix(prev.Timestamp, 0))
+	if !prev.Stopped {
+		shutdown = fmt.Sprintf("Previous shutdown time is unknown, "+
+			"last seen at [%s]", time.Unix(prev.Timestamp, 0))
+	}
+	var msg string
+	if prev.BootId != cur.BootId {
+		powerOn := time.Now().Add(-time.Duration(cur.Uptime * float64(time.Second)))
+		msg = fmt.Sprintf("Host has been rebooted, power-on at [%s]. %s",
+			powerOn.Truncate(time.Second), shutdown)
+	} else {
+		msg = fmt.Sprintf("Atella has been restarted without host reboot. %s",
+			shutdown)
+	}
+	c.Logger.LogSystem(fmt.Sprintf("[Boot] %s", msg))
+	c.Report(msg, "all")
+	c.SaveBootState(false)
+}
+
+// Function save boot state every interval, so time of unexpected shutdown
+// is known with interval accuracy
+func (c *Config) BootStateSaver() {
+	c.reporter.scheduler.Every(Seconds(c.Agent.Interval),
+		func(ctx context.Context) {
+			c.SaveBootState(false)
+		})
+}
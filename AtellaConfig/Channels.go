@@ -1,6 +1,7 @@
 package AtellaConfig
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -10,7 +11,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
+	"../AtellaCheck"
+	"../AtellaGraphiteChannel"
 	"../AtellaMailChannel"
 	"../AtellaTgSibnetChannel"
 )
@@ -29,7 +33,22 @@ type msg struct {
 	Message string `json:"message"`
 }
 
+// Message in spool, waiting for sending
+type SpoolMessage struct {
+	Id      string `json:"id"`
+	Target  string `json:"target"`
+	Message string `json:"message"`
+}
+
+// Counters of sending attempts via channel
+type ChannelStats struct {
+	Success uint64 `json:"success"`
+	Failure uint64 `json:"failure"`
+}
+
 var (
+	// Channels of "all" target. Graphite receives only reports, which are
+	// targeted to it explicitly
 	defaultChannels []string = []string{"tgsibnet", "mail"}
 )
 
@@ -48,6 +67,30 @@ func (conf *Config) Init() {
 	var rp interface{}
 	conf.Logger.Init(conf.Agent.LogLevel, conf.Agent.LogFile)
 	conf.reporter.isLocked = false
+	if err := conf.LoadTLS(); err != nil {
+		conf.Logger.LogError(fmt.Sprintf("Error loading TLS files. %s", err))
+	}
+	conf.ResolveLocalMaster()
+	for _, t := range conf.Security.Tokens {
+		if !IsRole(t.Role) {
+			conf.Logger.LogWarning(fmt.Sprintf("Unknown role %s of token %s",
+				t.Role, t.Name))
+		}
+	}
+	for _, s := range conf.Sectors {
+		for _, check := range s.Config.Checks {
+			if !AtellaCheck.IsType(check.Type) {
+				conf.Logger.LogWarning(fmt.Sprintf("Unknown type %s of check %s in sector %s",
+					check.Type, check.GetName(), s.Sector))
+			}
+		}
+	}
+	for _, check := range conf.Agent.LocalChecks {
+		if !AtellaCheck.IsType(check.Type) {
+			conf.Logger.LogWarning(fmt.Sprintf("Unknown type %s of local check %s",
+				check.Type, check.GetName()))
+		}
+	}
 	for i := range conf.Channels {
 		rp = conf.Channels[i].Config
 		switch conf.Channels[i].Channel {
@@ -63,6 +106,10 @@ func (conf *Config) Init() {
 			conf.Logger.LogInfo(fmt.Sprintf(
 				"Init Mail Channel with params: %v",
 				(*rp.(*AtellaMailChannel.AtellaMailConfig))))
+		case "Graphite":
+			conf.Logger.LogInfo(fmt.Sprintf(
+				"Init Graphite Channel with params: %v",
+				(*rp.(*AtellaGraphiteChannel.AtellaGraphiteConfig))))
 		default:
 			conf.Logger.LogWarning(fmt.Sprintf("Unknown channel %s",
 				conf.Channels[i].Channel))
@@ -70,23 +117,20 @@ func (conf *Config) Init() {
 	}
 }
 
+// Function stop sender jobs and wait until current iterations finish
 func (conf *Config) StopSender() {
 	conf.Logger.LogSystem("Sender request stop")
-	conf.reporter.stopRequest = true
-	for !conf.reporter.stopReply {
-	}
+	conf.reporter.scheduler.Stop()
 	conf.Logger.LogSystem("Sender stopped")
 }
 
+// Function send reports every 10 seconds. Blocks until sender stopped
 func (conf *Config) Sender() {
-	for {
+	conf.Send()
+	conf.reporter.scheduler.Every(10*time.Second, func(ctx context.Context) {
 		conf.Send()
-		Pause(10, &conf.reporter.stopRequest)
-		if conf.reporter.stopRequest {
-			conf.reporter.stopReply = true
-			break
-		}
-	}
+	})
+	<-conf.reporter.scheduler.Done()
 }
 
 // Function call send-report mechanism. Use files created by Report function.
@@ -144,6 +188,7 @@ func (conf *Config) Send() {
 					if err != nil {
 						conf.Logger.LogError(fmt.Sprintf("%s", err))
 					}
+					conf.countSend("tgsibnet", res && err == nil)
 				}
 			} else if target == "mail" {
 				if conf.Channels["Mail"] != nil {
@@ -152,6 +197,16 @@ func (conf *Config) Send() {
 					if err != nil {
 						conf.Logger.LogError(fmt.Sprintf("%s", err))
 					}
+					conf.countSend("mail", res && err == nil)
+				}
+			} else if target == "graphite" {
+				if conf.Channels["Graphite"] != nil {
+					res, err = conf.Channels["Graphite"].Config.SendMessage(
+						m.Message, conf.Agent.Hostname)
+					if err != nil {
+						conf.Logger.LogError(fmt.Sprintf("%s", err))
+					}
+					conf.countSend("graphite", res && err == nil)
 				}
 			} else {
 				conf.Logger.LogError(fmt.Sprintf("Unsopported channel - %s", target))
@@ -167,6 +222,65 @@ func (conf *Config) Send() {
 	conf.reporter.isLocked = false
 }
 
+// Function count attempt of sending message via channel
+func (conf *Config) countSend(channel string, success bool) {
+	conf.reporter.statsMux.Lock()
+	defer conf.reporter.statsMux.Unlock()
+	if conf.reporter.stats == nil {
+		conf.reporter.stats = make(map[string]ChannelStats)
+	}
+	stats := conf.reporter.stats[channel]
+	if success {
+		stats.Success = stats.Success + 1
+	} else {
+		stats.Failure = stats.Failure + 1
+	}
+	conf.reporter.stats[channel] = stats
+}
+
+// Function return copy of sending counters by channel
+func (conf *Config) GetChannelStats() map[string]ChannelStats {
+	conf.reporter.statsMux.Lock()
+	defer conf.reporter.statsMux.Unlock()
+	res := make(map[string]ChannelStats)
+	for channel, stats := range conf.reporter.stats {
+		res[channel] = stats
+	}
+	return res
+}
+
+// Function return messages from spool, created by Report function.
+func (conf *Config) GetMessages() ([]SpoolMessage, error) {
+	var (
+		m        msg
+		messages []SpoolMessage = make([]SpoolMessage, 0)
+	)
+	files, err := ioutil.ReadDir(conf.Agent.MessagePath)
+	if err != nil {
+		return messages, err
+	}
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		data, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", conf.Agent.MessagePath,
+			file.Name()))
+		if err != nil {
+			conf.Logger.LogError(fmt.Sprintf("%s", err))
+			continue
+		}
+		if err = json.Unmarshal(data, &m); err != nil {
+			conf.Logger.LogError(fmt.Sprintf("%s", err))
+			continue
+		}
+		messages = append(messages, SpoolMessage{
+			Id:      file.Name(),
+			Target:  m.Target,
+			Message: m.Message})
+	}
+	return messages, nil
+}
+
 // Function save report as a file (filename are random hex string).
 func (conf *Config) Report(message string, target string) string {
 	var (
@@ -0,0 +1,175 @@
+package AtellaConfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Score of master, when no master was tried yet
+	MaxDeliveryScore float64 = 100
+)
+
+// Health of vector delivery to master. Score is success rate of last
+// deliveries in percents minus average latency in tens of milliseconds.
+// Last deliveries expire, if master was not tried for rebalance_interval
+type DeliveryStats struct {
+	Success             uint64       `json:"success"`
+	Failure             uint64       `json:"failure"`
+	ConsecutiveFailures uint64       `json:"consecutive_failures"`
+	LastSuccess         int64        `json:"last_success"`
+	LastError           string       `json:"last_error"`
+	Latency             LatencyStats `json:"latency"`
+	Score               float64      `json:"score"`
+	LastAttempt         int64        `json:"last_attempt"`
+	results             []bool
+}
+
+// Delivery health by master
+type delivery struct {
+	mux   sync.Mutex
+	stats map[string]DeliveryStats
+}
+
+// Function return copy of delivery health
+func (s DeliveryStats) Copy() DeliveryStats {
+	res := s
+	res.Latency = s.Latency.Copy()
+	if s.results != nil {
+		res.results = make([]bool, len(s.results))
+		copy(res.results, s.results)
+	}
+	return res
+}
+
+// Function count attempt of vector delivery to master. Latency of
+// successful delivery are in milliseconds. Only last rtt_window attempts
+// are scored. Return updated health of master
+func (c *Config) CountDelivery(master string, latency float64,
+	err error) DeliveryStats {
+	c.delivery.mux.Lock()
+	defer c.delivery.mux.Unlock()
+	if c.delivery.stats == nil {
+		c.delivery.stats = make(map[string]DeliveryStats)
+	}
+	now := time.Now().Unix()
+	stats := c.delivery.stats[master].Copy()
+	if c.isDeliveryExpired(stats, now) {
+		stats.results = nil
+	}
+	stats.LastAttempt = now
+	if err == nil {
+		stats.Success = stats.Success + 1
+		stats.ConsecutiveFailures = 0
+		stats.LastSuccess = now
+		stats.Latency.Add(latency, c.Agent.RttWindow)
+	} else {
+		stats.Failure = stats.Failure + 1
+		stats.ConsecutiveFailures = stats.ConsecutiveFailures + 1
+		stats.LastError = fmt.Sprintf("%s", err)
+	}
+
+	window := c.Agent.RttWindow
+	if window < 1 {
+		window = 1
+	}
+	stats.results = append(stats.results, err == nil)
+	if int64(len(stats.results)) > window {
+		stats.results = stats.results[int64(len(stats.results))-window:]
+	}
+	success := 0
+	for _, ok := range stats.results {
+		if ok {
+			success = success + 1
+		}
+	}
+	stats.Score = float64(success)*100/float64(len(stats.results)) -
+		stats.Latency.Avg/10
+	c.delivery.stats[master] = stats
+	return stats.Copy()
+}
+
+// Function return copy of delivery health by master
+func (c *Config) GetDeliveryStats() map[string]DeliveryStats {
+	c.delivery.mux.Lock()
+	defer c.delivery.mux.Unlock()
+	res := make(map[string]DeliveryStats)
+	for master, stats := range c.delivery.stats {
+		res[master] = stats.Copy()
+	}
+	return res
+}
+
+// Function return address of master by index in master_servers list
+func (c *Config) GetMasterAddress(i int) string {
+	return strings.Split(c.MasterServers.Hosts[i], " ")[0]
+}
+
+// Function return priority of master by index in master_servers list
+func (c *Config) GetMasterPriority(i int) int64 {
+	entry := strings.Split(c.MasterServers.Hosts[i], " ")
+	for _, p := range c.MasterServers.Priorities {
+		if stringElExists(entry, p.Host) {
+			return p.Priority
+		}
+	}
+	return 0
+}
+
+// Function check, that last deliveries to master are too old to be
+// scored. Results never expire, if rebalance_interval is not set
+func (c *Config) isDeliveryExpired(stats DeliveryStats, now int64) bool {
+	interval := c.MasterServers.RebalanceInterval
+	return interval > 0 && now-stats.LastAttempt > interval
+}
+
+// Function return score of master by index in master_servers list.
+// Master, which was not tried or which results expired, has mean score
+// of other masters, so it is neither preferred nor avoided
+func (c *Config) GetMasterScore(i int) float64 {
+	c.delivery.mux.Lock()
+	defer c.delivery.mux.Unlock()
+	now := time.Now().Unix()
+	stats, exist := c.delivery.stats[c.GetMasterAddress(i)]
+	if exist && !c.isDeliveryExpired(stats, now) {
+		return stats.Score
+	}
+	var (
+		sum   float64 = 0
+		count int     = 0
+	)
+	for j := range c.MasterServers.Hosts {
+		stats, exist := c.delivery.stats[c.GetMasterAddress(j)]
+		if exist && !c.isDeliveryExpired(stats, now) {
+			sum = sum + stats.Score
+			count = count + 1
+		}
+	}
+	if count == 0 {
+		return MaxDeliveryScore
+	}
+	return sum / float64(count)
+}
+
+// Function return indexes of masters in master_servers list, ordered by
+// priority, then by score. Masters with same rank keep order of list
+func (c *Config) RankMasters() []int {
+	res := make([]int, len(c.MasterServers.Hosts))
+	priority := make([]int64, len(res))
+	score := make([]float64, len(res))
+	for i := range res {
+		res[i] = i
+		priority[i] = c.GetMasterPriority(i)
+		score[i] = c.GetMasterScore(i)
+	}
+	sort.SliceStable(res, func(a, b int) bool {
+		if priority[res[a]] != priority[res[b]] {
+			return priority[res[a]] > priority[res[b]]
+		}
+		return score[res[a]] > score[res[b]]
+	})
+	return res
+}
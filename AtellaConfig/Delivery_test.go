@@ -0,0 +1,81 @@
+package AtellaConfig
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRankMasters(t *testing.T) {
+	hosts := []string{"192.0.2.1 a", "192.0.2.2 b", "192.0.2.3 c"}
+	fail := fmt.Errorf("failed")
+	tests := []struct {
+		name       string
+		priorities []*MasterPriorityConfig
+		deliveries func(c *Config)
+		want       []int
+	}{
+		{"keep order of list", nil, func(c *Config) {}, []int{0, 1, 2}},
+		{"priority first", []*MasterPriorityConfig{{Host: "c", Priority: 10}},
+			func(c *Config) {}, []int{2, 0, 1}},
+		{"failed master is last", nil, func(c *Config) {
+			c.CountDelivery("192.0.2.1", 0, nil)
+			c.CountDelivery("192.0.2.2", 0, fail)
+		}, []int{0, 2, 1}},
+		{"priority over score", []*MasterPriorityConfig{{Host: "b", Priority: 1}},
+			func(c *Config) {
+				c.CountDelivery("192.0.2.2", 0, fail)
+			}, []int{1, 0, 2}},
+		{"untried master is not preferred", nil, func(c *Config) {
+			c.CountDelivery("192.0.2.2", 0, nil)
+			c.CountDelivery("192.0.2.3", 0, fail)
+		}, []int{1, 0, 2}},
+		{"failed master recovers after expire", nil, func(c *Config) {
+			c.CountDelivery("192.0.2.1", 0, fail)
+			c.CountDelivery("192.0.2.2", 0, nil)
+			stats := c.delivery.stats["192.0.2.1"]
+			stats.LastAttempt = time.Now().Unix() - 301
+			c.delivery.stats["192.0.2.1"] = stats
+		}, []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			c.MasterServers.Hosts = hosts
+			c.MasterServers.RebalanceInterval = 300
+			if tt.priorities != nil {
+				c.MasterServers.Priorities = tt.priorities
+			}
+			tt.deliveries(c)
+			if got := c.RankMasters(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RankMasters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMasterScore(t *testing.T) {
+	c := NewConfig()
+	c.MasterServers.Hosts = []string{"192.0.2.1 a", "192.0.2.2 b",
+		"192.0.2.3 c"}
+	if got := c.GetMasterScore(0); got != MaxDeliveryScore {
+		t.Errorf("score without deliveries = %v, want %v", got, MaxDeliveryScore)
+	}
+	c.CountDelivery("192.0.2.1", 0, nil)
+	c.CountDelivery("192.0.2.2", 0, nil)
+	c.CountDelivery("192.0.2.2", 0, fmt.Errorf("failed"))
+	tests := []struct {
+		i    int
+		want float64
+	}{
+		{0, 100},
+		{1, 50},
+		{2, 75},
+	}
+	for _, tt := range tests {
+		if got := c.GetMasterScore(tt.i); got != tt.want {
+			t.Errorf("GetMasterScore(%d) = %v, want %v", tt.i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package AtellaConfig
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"../AtellaGraphiteChannel"
+)
+
+// Function convert bool status into metric value
+func statusValue(status bool) float64 {
+	if status {
+		return 1
+	}
+	return 0
+}
+
+// Function append latency statistics into metrics array
+func appendLatencyMetrics(metrics []AtellaGraphiteChannel.Metric,
+	graphite *AtellaGraphiteChannel.AtellaGraphiteConfig, stats LatencyStats,
+	now int64, nodes ...string) []AtellaGraphiteChannel.Metric {
+	if stats.Count < 1 {
+		return metrics
+	}
+	values := map[string]float64{
+		"min": stats.Min,
+		"avg": stats.Avg,
+		"max": stats.Max}
+	for _, name := range []string{"min", "avg", "max"} {
+		metrics = append(metrics, AtellaGraphiteChannel.Metric{
+			Path:      graphite.Path(append(nodes, name)...),
+			Value:     values[name],
+			Timestamp: now})
+	}
+	return metrics
+}
+
+// Function append status, last-probe timestamp, latency and round-trip
+// statistics metrics of vector element into metrics array
+func appendVectorMetrics(metrics []AtellaGraphiteChannel.Metric,
+	graphite *AtellaGraphiteChannel.AtellaGraphiteConfig, vec VectorType,
+	now int64, nodes ...string) []AtellaGraphiteChannel.Metric {
+	path := append(nodes, vec.Host)
+	metrics = append(metrics,
+		AtellaGraphiteChannel.Metric{
+			Path:      graphite.Path(append(path, "status")...),
+			Value:     statusValue(vec.Status),
+			Timestamp: now},
+		AtellaGraphiteChannel.Metric{
+			Path:      graphite.Path(append(path, "timestamp")...),
+			Value:     float64(vec.Timestamp),
+			Timestamp: now},
+		AtellaGraphiteChannel.Metric{
+			Path:      graphite.Path(append(path, "latency")...),
+			Value:     vec.Latency,
+			Timestamp: now})
+	metrics = appendLatencyMetrics(metrics, graphite, vec.Rtt, now,
+		append(path, "rtt")...)
+	metrics = appendLatencyMetrics(metrics, graphite, vec.DialTime, now,
+		append(path, "dial_time")...)
+	for _, check := range vec.Checks {
+		metrics = append(metrics, AtellaGraphiteChannel.Metric{
+			Path:      graphite.Path(append(path, "checks", check.Name, "status")...),
+			Value:     statusValue(check.Status),
+			Timestamp: now})
+	}
+	if vec.Info != nil {
+		metrics = append(metrics,
+			AtellaGraphiteChannel.Metric{
+				Path:      graphite.Path(append(path, "info", "load1")...),
+				Value:     vec.Info.Load1,
+				Timestamp: now},
+			AtellaGraphiteChannel.Metric{
+				Path:      graphite.Path(append(path, "info", "memory")...),
+				Value:     vec.Info.MemPercent,
+				Timestamp: now})
+		for _, d := range vec.Info.Disks {
+			metrics = append(metrics, AtellaGraphiteChannel.Metric{
+				Path:      graphite.Path(append(path, "info", "disk", d.Mount)...),
+				Value:     d.Percent,
+				Timestamp: now})
+		}
+	}
+	return metrics
+}
+
+// Function return metrics, builded from Vector and MasterVector
+func (conf *Config) GetMetrics(
+	graphite *AtellaGraphiteChannel.AtellaGraphiteConfig) []AtellaGraphiteChannel.Metric {
+	var (
+		now     int64                          = time.Now().Unix()
+		metrics []AtellaGraphiteChannel.Metric = make([]AtellaGraphiteChannel.Metric, 0)
+	)
+
+	for _, vec := range conf.Vector.Snapshot() {
+		metrics = appendVectorMetrics(metrics, graphite, vec, now,
+			conf.Agent.Hostname, "neighbours")
+	}
+
+	if conf.Agent.Master {
+		conf.MasterVectorMutex.RLock()
+		for reporter, vector := range conf.MasterVector {
+			for _, vec := range vector {
+				metrics = appendVectorMetrics(metrics, graphite, vec, now,
+					conf.Agent.Hostname, "master", reporter)
+			}
+		}
+		conf.MasterVectorMutex.RUnlock()
+	}
+	return metrics
+}
+
+// Function send metrics to Graphite Channel if it configured
+func (conf *Config) SendMetrics() {
+	if conf.Channels["Graphite"] == nil {
+		return
+	}
+	graphite, ok := conf.Channels["Graphite"].Config.(*AtellaGraphiteChannel.AtellaGraphiteConfig)
+	if !ok || graphite.Disabled {
+		return
+	}
+	metrics := conf.GetMetrics(graphite)
+	err := graphite.SendMetrics(metrics)
+	if err != nil {
+		conf.Logger.LogError(fmt.Sprintf("[Graphite] %s", err))
+		return
+	}
+	conf.Logger.LogInfo(fmt.Sprintf("[Graphite] Sent %d metrics", len(metrics)))
+}
+
+// Function schedule sending metrics every interval until sender stopped
+func (conf *Config) MetricsSender() {
+	conf.reporter.scheduler.Every(Seconds(conf.Agent.Interval),
+		func(ctx context.Context) {
+			conf.SendMetrics()
+		})
+}
@@ -0,0 +1,44 @@
+package AtellaConfig
+
+// Function apply result of probe to vector element with hysteresis.
+// Status goes down after fail consecutive failures and goes up after rise
+// consecutive successes. Host, which changed status more than flapCount
+// times during flapWindow seconds, are marked as flapping. First probe of
+// host sets status at once. Return true if status changed
+func (vec *VectorType) ApplyProbe(ok bool, now int64, rise int64, fail int64,
+	flapCount int64, flapWindow int64) bool {
+	probed := vec.IsProbed()
+	if ok {
+		vec.Successes = vec.Successes + 1
+		vec.Failures = 0
+	} else {
+		vec.Failures = vec.Failures + 1
+		vec.Successes = 0
+	}
+
+	changed := false
+	if !probed {
+		vec.Status = ok
+	} else if ok && !vec.Status && vec.Successes >= rise {
+		changed = true
+	} else if !ok && vec.Status && vec.Failures >= fail {
+		changed = true
+	}
+	if changed {
+		vec.Status = ok
+		vec.Transitions = vec.Transitions + 1
+		vec.changes = append(vec.changes, now)
+	}
+
+	// Only changes in window are counted
+	recent := make([]int64, 0)
+	for _, t := range vec.changes {
+		if now-t < flapWindow {
+			recent = append(recent, t)
+		}
+	}
+	vec.changes = recent
+	vec.RecentTransitions = int64(len(recent))
+	vec.Flapping = flapCount > 0 && vec.RecentTransitions > flapCount
+	return changed
+}
@@ -0,0 +1,57 @@
+package AtellaConfig
+
+import (
+	"testing"
+)
+
+func TestApplyProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		probes  []bool
+		status  bool
+		changes int64
+	}{
+		{"first probe sets up", []bool{true}, true, 0},
+		{"first probe sets down", []bool{false}, false, 0},
+		{"single failure is ignored", []bool{true, false, false}, true, 0},
+		{"fail count goes down", []bool{true, false, false, false}, false, 1},
+		{"interrupted failures", []bool{true, false, false, true, false, false},
+			true, 0},
+		{"rise count goes up", []bool{false, true, true}, true, 1},
+		{"single success is ignored", []bool{false, true}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vec := VectorType{Hostname: "unknown"}
+			for i, ok := range tt.probes {
+				// Probe fills stage after result is applied
+				vec.ApplyProbe(ok, int64(i), 2, 3, 0, 600)
+				vec.Stage = "done"
+			}
+			if vec.Status != tt.status || vec.Transitions != tt.changes {
+				t.Errorf("status = %v after %d changes, want %v after %d",
+					vec.Status, vec.Transitions, tt.status, tt.changes)
+			}
+		})
+	}
+}
+
+func TestApplyProbeFlapping(t *testing.T) {
+	vec := VectorType{Hostname: "host", Stage: "done", Status: true}
+	now := int64(1000)
+	// Every change needs one probe with rise = fail = 1
+	for i := 0; i < 5; i = i + 1 {
+		now = now + 10
+		vec.ApplyProbe(i%2 == 1, now, 1, 1, 4, 600)
+	}
+	if !vec.Flapping || vec.RecentTransitions != 5 {
+		t.Errorf("flapping = %v with %d changes, want true with 5",
+			vec.Flapping, vec.RecentTransitions)
+	}
+	// Changes leave the window
+	vec.ApplyProbe(false, now+600, 1, 1, 4, 600)
+	if vec.Flapping || vec.RecentTransitions != 0 {
+		t.Errorf("flapping = %v with %d changes after window, want false with 0",
+			vec.Flapping, vec.RecentTransitions)
+	}
+}
@@ -0,0 +1,229 @@
+package AtellaConfig
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Usage of mounted filesystem
+type DiskInfo struct {
+	Mount   string  `json:"mount"`
+	Device  string  `json:"device"`
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Free    uint64  `json:"free"`
+	Percent float64 `json:"percent"`
+}
+
+// Local health of host, collected from /proc
+type HostInfo struct {
+	Hostname     string     `json:"hostname"`
+	Timestamp    int64      `json:"timestamp"`
+	Cpus         int        `json:"cpus"`
+	Load1        float64    `json:"load1"`
+	Load5        float64    `json:"load5"`
+	Load15       float64    `json:"load15"`
+	MemTotal     uint64     `json:"mem_total"`
+	MemAvailable uint64     `json:"mem_available"`
+	MemPercent   float64    `json:"mem_percent"`
+	Disks        []DiskInfo `json:"disks"`
+	Uptime       float64    `json:"uptime"`
+	BootId       string     `json:"boot_id"`
+}
+
+// Health problem of host. Key identifies problem, e.g. "disk:/"
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+var (
+	procPath string = "/proc"
+)
+
+// Function collect local health of host. Parts, which couldn.t be read,
+// are left empty and returned as error
+func (c *Config) GetInfo() (*HostInfo, error) {
+	info := &HostInfo{
+		Hostname:  c.Agent.Hostname,
+		Timestamp: time.Now().Unix(),
+		Cpus:      runtime.NumCPU(),
+		Disks:     make([]DiskInfo, 0)}
+	errs := make([]string, 0)
+	for _, read := range []func(*HostInfo) error{readLoad, readMemory,
+		readDisks, readUptime, readBootId} {
+		if err := read(info); err != nil {
+			errs = append(errs, fmt.Sprintf("%s", err))
+		}
+	}
+	if len(errs) > 0 {
+		return info, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return info, nil
+}
+
+// Function return deep copy of info
+func (info *HostInfo) Copy() *HostInfo {
+	if info == nil {
+		return nil
+	}
+	res := *info
+	if info.Disks != nil {
+		res.Disks = make([]DiskInfo, len(info.Disks))
+		copy(res.Disks, info.Disks)
+	}
+	return &res
+}
+
+func readLoad(info *HostInfo) error {
+	data, err := ioutil.ReadFile(procPath + "/loadavg")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return fmt.Errorf("Bad loadavg")
+	}
+	info.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	info.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	info.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	return nil
+}
+
+func readMemory(info *HostInfo) error {
+	f, err := os.Open(procPath + "/meminfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		// Values are in kB
+		value, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			info.MemTotal = value * 1024
+		case "MemAvailable:":
+			info.MemAvailable = value * 1024
+		}
+	}
+	if info.MemTotal > 0 {
+		info.MemPercent = float64(info.MemTotal-info.MemAvailable) * 100 /
+			float64(info.MemTotal)
+	}
+	return scanner.Err()
+}
+
+// Only filesystems on block devices are collected
+func readDisks(info *HostInfo) error {
+	var st syscall.Statfs_t
+	f, err := os.Open(procPath + "/mounts")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		if diskExists(info.Disks, fields[1]) {
+			continue
+		}
+		if err := syscall.Statfs(fields[1], &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+		bsize := uint64(st.Bsize)
+		used := (uint64(st.Blocks) - uint64(st.Bfree)) * bsize
+		free := uint64(st.Bavail) * bsize
+		disk := DiskInfo{
+			Mount:  fields[1],
+			Device: fields[0],
+			Total:  uint64(st.Blocks) * bsize,
+			Used:   used,
+			Free:   free}
+		// As df does, reserved blocks are not counted
+		if used+free > 0 {
+			disk.Percent = float64(used) * 100 / float64(used+free)
+		}
+		info.Disks = append(info.Disks, disk)
+	}
+	return scanner.Err()
+}
+
+func readUptime(info *HostInfo) error {
+	data, err := ioutil.ReadFile(procPath + "/uptime")
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return fmt.Errorf("Bad uptime")
+	}
+	info.Uptime, err = strconv.ParseFloat(fields[0], 64)
+	return err
+}
+
+func readBootId(info *HostInfo) error {
+	data, err := ioutil.ReadFile(procPath + "/sys/kernel/random/boot_id")
+	if err != nil {
+		return err
+	}
+	info.BootId = strings.TrimSpace(string(data))
+	return nil
+}
+
+// Function check disks array and return true if mount exist
+func diskExists(disks []DiskInfo, mount string) bool {
+	for _, d := range disks {
+		if d.Mount == mount {
+			return true
+		}
+	}
+	return false
+}
+
+// Function return health problems of host by thresholds from config.
+// Zero threshold disables check
+func (c *Config) GetProblems(info *HostInfo) []Problem {
+	problems := make([]Problem, 0)
+	if info == nil {
+		return problems
+	}
+	host := info.Hostname
+	if t := c.Agent.LoadThreshold; t > 0 && info.Cpus > 0 &&
+		info.Load1/float64(info.Cpus) >= t {
+		problems = append(problems, Problem{
+			Key: "load",
+			Message: fmt.Sprintf("load %.2f on %d cpus on host %s", info.Load1,
+				info.Cpus, host)})
+	}
+	if t := c.Agent.MemoryThreshold; t > 0 && info.MemPercent >= t {
+		problems = append(problems, Problem{
+			Key:     "memory",
+			Message: fmt.Sprintf("memory %.0f%% on host %s", info.MemPercent, host)})
+	}
+	if t := c.Agent.DiskThreshold; t > 0 {
+		for _, d := range info.Disks {
+			if d.Percent >= t {
+				problems = append(problems, Problem{
+					Key: fmt.Sprintf("disk:%s", d.Mount),
+					Message: fmt.Sprintf("disk %.0f%% on %s on host %s", d.Percent,
+						d.Mount, host)})
+			}
+		}
+	}
+	return problems
+}
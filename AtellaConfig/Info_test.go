@@ -0,0 +1,95 @@
+package AtellaConfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetInfo(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"loadavg":                   "4.00 2.00 1.00 1/100 123\n",
+		"meminfo":                   "MemTotal: 1000 kB\nMemFree: 100 kB\nMemAvailable: 250 kB\n",
+		"mounts":                    "proc /proc proc rw 0 0\n",
+		"uptime":                    "123.45 100.00\n",
+		"sys/kernel/random/boot_id": "boot\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := procPath
+	procPath = dir
+	defer func() { procPath = saved }()
+
+	c := NewConfig()
+	info, err := c.GetInfo()
+	if err != nil {
+		t.Fatalf("GetInfo() error: %s", err)
+	}
+	if info.Load1 != 4 || info.MemTotal != 1000*1024 || info.MemPercent != 75 ||
+		info.Uptime != 123.45 || info.BootId != "boot" || len(info.Disks) != 0 {
+		t.Errorf("GetInfo() = %+v", info)
+	}
+
+	procPath = filepath.Join(dir, "none")
+	if _, err := c.GetInfo(); err == nil {
+		t.Errorf("GetInfo() without proc succeeded")
+	}
+}
+
+func TestGetProblems(t *testing.T) {
+	info := &HostInfo{
+		Hostname:   "host",
+		Cpus:       2,
+		Load1:      4,
+		MemPercent: 75,
+		Disks: []DiskInfo{
+			{Mount: "/", Percent: 95},
+			{Mount: "/home", Percent: 10}}}
+	tests := []struct {
+		name   string
+		load   float64
+		memory float64
+		disk   float64
+		want   []string
+	}{
+		{"disabled", 0, 0, 0, []string{}},
+		{"load per cpu", 2, 0, 0, []string{"load"}},
+		{"load under threshold", 3, 0, 0, []string{}},
+		{"memory", 0, 75, 0, []string{"memory"}},
+		{"disk by mount", 0, 0, 90, []string{"disk:/"}},
+		{"all", 1, 50, 5, []string{"load", "memory", "disk:/", "disk:/home"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			c.Agent.LoadThreshold = tt.load
+			c.Agent.MemoryThreshold = tt.memory
+			c.Agent.DiskThreshold = tt.disk
+			problems := c.GetProblems(info)
+			keys := make([]string, 0)
+			for _, p := range problems {
+				keys = append(keys, p.Key)
+			}
+			if len(keys) != len(tt.want) {
+				t.Fatalf("GetProblems() = %v, want %v", keys, tt.want)
+			}
+			for i := range keys {
+				if keys[i] != tt.want[i] {
+					t.Errorf("GetProblems() = %v, want %v", keys, tt.want)
+				}
+			}
+		})
+	}
+	if problems := NewConfig().GetProblems(nil); len(problems) != 0 {
+		t.Errorf("GetProblems(nil) = %v", problems)
+	}
+}
@@ -0,0 +1,74 @@
+package AtellaConfig
+
+// Latency statistics in milliseconds over sliding window of samples
+type LatencyStats struct {
+	Last    float64 `json:"last"`
+	Min     float64 `json:"min"`
+	Avg     float64 `json:"avg"`
+	Max     float64 `json:"max"`
+	Count   int64   `json:"count"`
+	samples []float64
+}
+
+// Function add sample and recompute statistics. Only last window samples
+// are kept
+func (l *LatencyStats) Add(sample float64, window int64) {
+	if window < 1 {
+		window = 1
+	}
+	l.samples = append(l.samples, sample)
+	if int64(len(l.samples)) > window {
+		l.samples = l.samples[int64(len(l.samples))-window:]
+	}
+	l.Last = sample
+	l.Min = sample
+	l.Max = sample
+	sum := 0.0
+	for _, s := range l.samples {
+		if s < l.Min {
+			l.Min = s
+		}
+		if s > l.Max {
+			l.Max = s
+		}
+		sum = sum + s
+	}
+	l.Count = int64(len(l.samples))
+	l.Avg = sum / float64(l.Count)
+}
+
+// Function merge statistics of other reporter: min of minimums, max of
+// maximums and average weighted by count of samples
+func (l *LatencyStats) Merge(other LatencyStats) {
+	if other.Count < 1 {
+		return
+	}
+	if l.Count < 1 {
+		l.Last = other.Last
+		l.Min = other.Min
+		l.Avg = other.Avg
+		l.Max = other.Max
+		l.Count = other.Count
+		return
+	}
+	if other.Min < l.Min {
+		l.Min = other.Min
+	}
+	if other.Max > l.Max {
+		l.Max = other.Max
+	}
+	l.Avg = (l.Avg*float64(l.Count) + other.Avg*float64(other.Count)) /
+		float64(l.Count+other.Count)
+	l.Count = l.Count + other.Count
+	l.Last = other.Last
+}
+
+// Function return deep copy of statistics
+func (l LatencyStats) Copy() LatencyStats {
+	res := l
+	if l.samples != nil {
+		res.samples = make([]float64, len(l.samples))
+		copy(res.samples, l.samples)
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package AtellaConfig
+
+import (
+	"testing"
+)
+
+func TestLatencyStatsAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		window  int64
+		want    LatencyStats
+	}{
+		{"one sample", []float64{5}, 10,
+			LatencyStats{Last: 5, Min: 5, Avg: 5, Max: 5, Count: 1}},
+		{"several samples", []float64{1, 3, 2}, 10,
+			LatencyStats{Last: 2, Min: 1, Avg: 2, Max: 3, Count: 3}},
+		{"window drops old samples", []float64{100, 1, 3}, 2,
+			LatencyStats{Last: 3, Min: 1, Avg: 2, Max: 3, Count: 2}},
+		{"zero window keeps last", []float64{7, 9}, 0,
+			LatencyStats{Last: 9, Min: 9, Avg: 9, Max: 9, Count: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l LatencyStats
+			for _, s := range tt.samples {
+				l.Add(s, tt.window)
+			}
+			if !latencyEqual(l, tt.want) {
+				t.Errorf("Add() = %+v, want %+v", l, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyStatsMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		l     LatencyStats
+		other LatencyStats
+		want  LatencyStats
+	}{
+		{"empty other",
+			LatencyStats{Last: 1, Min: 1, Avg: 1, Max: 1, Count: 1},
+			LatencyStats{},
+			LatencyStats{Last: 1, Min: 1, Avg: 1, Max: 1, Count: 1}},
+		{"empty local",
+			LatencyStats{},
+			LatencyStats{Last: 2, Min: 1, Avg: 2, Max: 3, Count: 3},
+			LatencyStats{Last: 2, Min: 1, Avg: 2, Max: 3, Count: 3}},
+		{"weighted average",
+			LatencyStats{Last: 1, Min: 1, Avg: 1, Max: 1, Count: 3},
+			LatencyStats{Last: 5, Min: 5, Avg: 5, Max: 5, Count: 1},
+			LatencyStats{Last: 5, Min: 1, Avg: 2, Max: 5, Count: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.l
+			l.Merge(tt.other)
+			if !latencyEqual(l, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", l, tt.want)
+			}
+		})
+	}
+}
+
+// Function compare statistics without samples
+func latencyEqual(a LatencyStats, b LatencyStats) bool {
+	return a.Last == b.Last && a.Min == b.Min && a.Avg == b.Avg &&
+		a.Max == b.Max && a.Count == b.Count
+}
@@ -0,0 +1,166 @@
+package AtellaConfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State of leader election between master servers. Master is alive, if it
+// was seen by replication during lease. Leader is first alive master in
+// master_servers list, others are hot standbys. Master, which is not found
+// in list, is standby. Local entry name is resolved on config (re)load
+type election struct {
+	mux      sync.Mutex
+	started  int64
+	seen     map[string]int64
+	resolved bool
+	local    string
+}
+
+// Status of master in election
+type MasterStatus struct {
+	Host     string `json:"host"`
+	Alive    bool   `json:"alive"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// Status of leader election, seen by local master
+type LeaderStatus struct {
+	Leader   string         `json:"leader"`
+	Hostname string         `json:"hostname"`
+	IsLeader bool           `json:"is_leader"`
+	Lease    int64          `json:"lease"`
+	Masters  []MasterStatus `json:"masters"`
+}
+
+// Function start election. Masters, which were not seen yet, are alive
+// during first lease, so standby doesn.t become leader at start
+func (c *Config) StartElection() {
+	if len(c.MasterServers.Hosts) > 0 && c.LocalMasterName() == "" {
+		c.Logger.LogError(fmt.Sprintf(
+			"Master %s is not found in master_servers by hostname or local address, it is standby",
+			c.Agent.Hostname))
+	}
+	c.election.mux.Lock()
+	defer c.election.mux.Unlock()
+	c.election.started = time.Now().Unix()
+	c.election.seen = make(map[string]int64)
+}
+
+// Function save time, when master was seen. Host is address or hostname
+// of master_servers entry
+func (c *Config) MasterSeen(host string) {
+	name := c.MasterName(host)
+	if name == "" {
+		return
+	}
+	c.election.mux.Lock()
+	defer c.election.mux.Unlock()
+	if c.election.seen == nil {
+		c.election.seen = make(map[string]int64)
+	}
+	c.election.seen[name] = time.Now().Unix()
+}
+
+// Function find name of master_servers entry of local agent. Entry is
+// found by hostname, then by addresses of local interfaces. Return empty
+// string if local agent is not listed
+func (c *Config) findLocalMaster() string {
+	if name := c.MasterName(c.Agent.Hostname); name != "" {
+		return name
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			if name := c.MasterName(ipnet.IP.String()); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Function resolve name of master_servers entry of local agent. Called on
+// config (re)load, so interfaces are not listed on every election status
+func (c *Config) ResolveLocalMaster() string {
+	c.election.mux.Lock()
+	defer c.election.mux.Unlock()
+	c.election.local = c.findLocalMaster()
+	c.election.resolved = true
+	return c.election.local
+}
+
+// Function return name of master_servers entry of local agent or empty
+// string if it is not listed. Name is resolved at first call, if it was
+// not resolved yet
+func (c *Config) LocalMasterName() string {
+	c.election.mux.Lock()
+	resolved, local := c.election.resolved, c.election.local
+	c.election.mux.Unlock()
+	if !resolved {
+		return c.ResolveLocalMaster()
+	}
+	return local
+}
+
+// Function return status of election. Master without master_servers list
+// is leader of itself
+func (c *Config) GetLeaderStatus() *LeaderStatus {
+	res := &LeaderStatus{
+		Leader:   "",
+		Hostname: c.Agent.Hostname,
+		IsLeader: false,
+		Lease:    c.Agent.LeaderLease,
+		Masters:  make([]MasterStatus, 0)}
+	if !c.Agent.Master {
+		return res
+	}
+	if len(c.MasterServers.Hosts) < 1 {
+		res.Leader = c.Agent.Hostname
+		res.IsLeader = true
+		return res
+	}
+	me := c.LocalMasterName()
+
+	now := time.Now().Unix()
+	c.election.mux.Lock()
+	defer c.election.mux.Unlock()
+	for _, h := range c.MasterServers.Hosts {
+		entry := strings.Split(h, " ")
+		name := entry[len(entry)-1]
+		last, seen := c.election.seen[name]
+		status := MasterStatus{
+			Host:     name,
+			Alive:    name == me,
+			LastSeen: last}
+		if name == me {
+			status.LastSeen = now
+		} else if seen {
+			status.Alive = now-last < c.Agent.LeaderLease
+		} else {
+			status.Alive = now-c.election.started < c.Agent.LeaderLease
+		}
+		if status.Alive && res.Leader == "" {
+			res.Leader = name
+		}
+		res.Masters = append(res.Masters, status)
+	}
+	res.IsLeader = me != "" && res.Leader == me
+	return res
+}
+
+// Function return leader of masters or empty string if agent is not master
+func (c *Config) GetLeader() string {
+	return c.GetLeaderStatus().Leader
+}
+
+// Function return true if local agent is leader of masters
+func (c *Config) IsLeader() bool {
+	return c.GetLeaderStatus().IsLeader
+}
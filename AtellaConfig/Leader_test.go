@@ -0,0 +1,99 @@
+package AtellaConfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetLeaderStatus(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name     string
+		master   bool
+		hosts    []string
+		hostname string
+		started  int64
+		seen     map[string]int64
+		leader   string
+		isLeader bool
+	}{
+		{"not master", false, []string{"198.51.100.1 a"}, "a", now, nil, "", false},
+		{"single master", true, []string{}, "me", now, nil, "me", true},
+		{"first master leads", true, []string{"198.51.100.1 a", "198.51.100.2 b"},
+			"a", now, nil, "a", true},
+		{"unseen master alive during first lease", true,
+			[]string{"198.51.100.1 a", "198.51.100.2 b"}, "b", now, nil, "a", false},
+		{"unseen master dead after first lease", true,
+			[]string{"198.51.100.1 a", "198.51.100.2 b"}, "b", now - 60, nil, "b", true},
+		{"seen master leads", true, []string{"198.51.100.1 a", "198.51.100.2 b"},
+			"b", now - 60, map[string]int64{"a": now - 5}, "a", false},
+		{"expired master loses", true, []string{"198.51.100.1 a", "198.51.100.2 b"},
+			"b", now - 60, map[string]int64{"a": now - 31}, "b", true},
+		{"master found by address", true,
+			[]string{"198.51.100.1 a", "198.51.100.2 b"}, "198.51.100.2", now - 60, nil,
+			"b", true},
+		{"unlisted master is standby", true,
+			[]string{"198.51.100.1 a", "198.51.100.2 b"}, "x", now - 60, nil, "", false},
+		{"unlisted master follows leader", true,
+			[]string{"198.51.100.1 a", "198.51.100.2 b"}, "x", now - 60,
+			map[string]int64{"b": now}, "b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			c.Agent.Master = tt.master
+			c.Agent.Hostname = tt.hostname
+			c.Agent.LeaderLease = 30
+			c.MasterServers.Hosts = tt.hosts
+			c.election.started = tt.started
+			c.election.seen = tt.seen
+			if c.election.seen == nil {
+				c.election.seen = make(map[string]int64)
+			}
+			res := c.GetLeaderStatus()
+			if res.Leader != tt.leader || res.IsLeader != tt.isLeader {
+				t.Errorf("GetLeaderStatus() = %s, %v, want %s, %v", res.Leader,
+					res.IsLeader, tt.leader, tt.isLeader)
+			}
+		})
+	}
+}
+
+func TestMasterName(t *testing.T) {
+	c := NewConfig()
+	c.MasterServers.Hosts = []string{"198.51.100.1,198.51.100.11 a",
+		"198.51.100.2"}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"a", "a"},
+		{"198.51.100.1", "a"},
+		{"198.51.100.11", "a"},
+		{"198.51.100.2", "198.51.100.2"},
+		{"198.51.100.3", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := c.MasterName(tt.host); got != tt.want {
+			t.Errorf("MasterName(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestLocalMasterName(t *testing.T) {
+	c := NewConfig()
+	c.Agent.Hostname = "b"
+	c.MasterServers.Hosts = []string{"198.51.100.1 a", "198.51.100.2 b"}
+	if got := c.LocalMasterName(); got != "b" {
+		t.Fatalf("LocalMasterName() = %q, want b", got)
+	}
+	// Name is resolved once per config load
+	c.Agent.Hostname = "a"
+	if got := c.LocalMasterName(); got != "b" {
+		t.Errorf("LocalMasterName() before reload = %q, want b", got)
+	}
+	if got := c.ResolveLocalMaster(); got != "a" {
+		t.Errorf("ResolveLocalMaster() = %q, want a", got)
+	}
+}
@@ -0,0 +1,22 @@
+package AtellaConfig
+
+const (
+	// Stages of neighbour probe, in order of execution
+	StageDial     string = "dial"
+	StageHello    string = "hello"
+	StageAuth     string = "auth"
+	StageHostname string = "hostname"
+	StageHost     string = "host"
+	StageInfo     string = "info"
+	StageDone     string = "done"
+
+	// Reasons of failed probe
+	ReasonDialRefused      string = "dial_refused"
+	ReasonDialFailed       string = "dial_failed"
+	ReasonTimeout          string = "timeout"
+	ReasonConnClosed       string = "connection_closed"
+	ReasonAuthRejected     string = "auth_rejected"
+	ReasonHostnameMismatch string = "hostname_mismatch"
+	ReasonHostMismatch     string = "host_mismatch"
+	ReasonProtocolError    string = "protocol_error"
+)
@@ -0,0 +1,209 @@
+package AtellaConfig
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Space-separated line protocol
+	ProtocolText string = "text"
+	// Newline-delimited JSON frames
+	ProtocolJson string = "json"
+	// Protocol are negotiated by hello
+	ProtocolAuto string = "auto"
+
+	// Version of JSON frames protocol
+	ProtocolVersion int = 2
+	// Version of agents, which doesn.t support hello
+	ProtocolVersionLegacy int = 1
+	// Seconds, during which hello isn.t sent to legacy agent
+	LegacyPeerTTL int64 = 600
+
+	// Connection are encrypted
+	CapTLS string = "tls"
+	// Challenge-response auth are supported
+	CapHmacAuth string = "hmac-auth"
+	// JSON frames protocol are supported
+	CapJsonFrames string = "json-frames"
+	// Local health are exposed by get info
+	CapHostInfo string = "host-info"
+	// Set master are supported. Capability doesn.t depend on role, master
+	// vector are accepted only by agent with master = true
+	CapReplication string = "replication"
+
+	StatusOk  string = "ok"
+	StatusErr string = "err"
+)
+
+// Addresses of legacy agents with expiry time. Legacy agent may not answer
+// hello at all, so timeout isn.t waited on every connection
+type legacyPeers struct {
+	mux    sync.Mutex
+	expiry map[string]int64
+}
+
+// Request frame of JSON protocol. Fields are filled depending on command
+type Request struct {
+	Version  int                    `json:"version,omitempty"`
+	Cmd      string                 `json:"cmd"`
+	Code     string                 `json:"code,omitempty"`
+	Hostname string                 `json:"hostname,omitempty"`
+	Digest   string                 `json:"digest,omitempty"`
+	Host     string                 `json:"host,omitempty"`
+	Vector   []VectorType           `json:"vector,omitempty"`
+	Master   map[string]MasterEntry `json:"master,omitempty"`
+	Hello    *Hello                 `json:"hello,omitempty"`
+}
+
+// Response frame of JSON protocol
+type Response struct {
+	Version  int                     `json:"version"`
+	Cmd      string                  `json:"cmd"`
+	Status   string                  `json:"status"`
+	Error    string                  `json:"error,omitempty"`
+	Value    string                  `json:"value,omitempty"`
+	Nonce    string                  `json:"nonce,omitempty"`
+	Vector   []VectorType            `json:"vector,omitempty"`
+	Master   map[string][]VectorType `json:"master,omitempty"`
+	Verdicts map[string]*VerdictType `json:"verdicts,omitempty"`
+	Commands []string                `json:"commands,omitempty"`
+	Hello    *Hello                  `json:"hello,omitempty"`
+	Info     *HostInfo               `json:"info,omitempty"`
+}
+
+// Hello exchanged at connection start
+type Hello struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Version         string   `json:"version"`
+	Hostname        string   `json:"hostname"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// Function return true if message looks like JSON frame
+func IsJsonFrame(message string) bool {
+	return strings.HasPrefix(strings.TrimLeft(message, " \t"), "{")
+}
+
+// Function return true if version of JSON frame is supported
+func IsSupportedVersion(version int) bool {
+	return version == ProtocolVersion
+}
+
+// Function return command verb, e.g. "set" for "set_vector"
+func CommandVerb(cmd string) string {
+	return strings.SplitN(cmd, "_", 2)[0]
+}
+
+// Function return subcommand of command, e.g. "vector" for "set_vector"
+func CommandSubcommand(cmd string) string {
+	parts := strings.SplitN(cmd, "_", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Function create successful response for command
+func NewResponse(cmd string) *Response {
+	return &Response{
+		Version: ProtocolVersion,
+		Cmd:     cmd,
+		Status:  StatusOk}
+}
+
+// Function create error response for command
+func NewErrorResponse(cmd string, e string) *Response {
+	return &Response{
+		Version: ProtocolVersion,
+		Cmd:     cmd,
+		Status:  StatusErr,
+		Error:   e}
+}
+
+// Function encode frame into one line
+func EncodeFrame(frame interface{}) ([]byte, error) {
+	res, err := json.Marshal(frame)
+	if err != nil {
+		return nil, err
+	}
+	return append(res, '\n'), nil
+}
+
+// Function return hello of local agent. Tls means, that connection is
+// encrypted. Capabilities are same for all roles, so missing capabilities
+// show only agents, which need upgrade
+func (c *Config) GetHello(tls bool) *Hello {
+	caps := []string{CapHmacAuth, CapJsonFrames, CapHostInfo, CapReplication}
+	if tls {
+		caps = append(caps, CapTLS)
+	}
+	return &Hello{
+		ProtocolVersion: ProtocolVersion,
+		Version:         Version,
+		Hostname:        c.Agent.Hostname,
+		Capabilities:    caps}
+}
+
+// Function return hello of agent, which doesn.t support hello
+func GetLegacyHello() *Hello {
+	return &Hello{
+		ProtocolVersion: ProtocolVersionLegacy,
+		Version:         "unknown",
+		Hostname:        "",
+		Capabilities:    make([]string, 0)}
+}
+
+// Function remember agent by address as legacy for LegacyPeerTTL seconds
+func (c *Config) SetLegacyPeer(address string) {
+	c.legacy.mux.Lock()
+	defer c.legacy.mux.Unlock()
+	if c.legacy.expiry == nil {
+		c.legacy.expiry = make(map[string]int64)
+	}
+	c.legacy.expiry[address] = time.Now().Unix() + LegacyPeerTTL
+}
+
+// Function return true if agent by address was found legacy during last
+// LegacyPeerTTL seconds. After expiry hello is sent again, so upgraded
+// agent are found
+func (c *Config) IsLegacyPeer(address string) bool {
+	c.legacy.mux.Lock()
+	defer c.legacy.mux.Unlock()
+	expiry, ok := c.legacy.expiry[address]
+	if ok && expiry <= time.Now().Unix() {
+		delete(c.legacy.expiry, address)
+		return false
+	}
+	return ok
+}
+
+// Function return capabilities, supported by both sides
+func CommonCapabilities(local []string, remote []string) []string {
+	common := make([]string, 0)
+	for _, c := range local {
+		if stringElExists(remote, c) {
+			common = append(common, c)
+		}
+	}
+	return common
+}
+
+// Function return capabilities of local side, which remote side doesn.t
+// support
+func MissingCapabilities(local []string, remote []string) []string {
+	missing := make([]string, 0)
+	for _, c := range local {
+		if !stringElExists(remote, c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Function return true if capability exist in list
+func HasCapability(caps []string, c string) bool {
+	return stringElExists(caps, c)
+}
@@ -0,0 +1,48 @@
+package AtellaConfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLegacyPeer(t *testing.T) {
+	c := NewConfig()
+	if c.IsLegacyPeer("198.51.100.1:5223") {
+		t.Error("IsLegacyPeer() = true for unknown peer")
+	}
+	c.SetLegacyPeer("198.51.100.1:5223")
+	if !c.IsLegacyPeer("198.51.100.1:5223") {
+		t.Error("IsLegacyPeer() = false for legacy peer")
+	}
+	c.legacy.expiry["198.51.100.1:5223"] = time.Now().Unix()
+	if c.IsLegacyPeer("198.51.100.1:5223") {
+		t.Error("IsLegacyPeer() = true after expiry")
+	}
+}
+
+func TestGetHelloRoles(t *testing.T) {
+	master := NewConfig()
+	master.Agent.Master = true
+	agent := NewConfig()
+	tests := []struct {
+		name   string
+		local  *Hello
+		remote *Hello
+	}{
+		{"agent to master", agent.GetHello(false), master.GetHello(false)},
+		{"master to agent", master.GetHello(false), agent.GetHello(false)},
+		{"tls", agent.GetHello(true), master.GetHello(true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if missing := MissingCapabilities(tt.local.Capabilities,
+				tt.remote.Capabilities); len(missing) != 0 {
+				t.Errorf("MissingCapabilities() = %v, want none", missing)
+			}
+		})
+	}
+	if missing := MissingCapabilities(agent.GetHello(false).Capabilities,
+		GetLegacyHello().Capabilities); len(missing) == 0 {
+		t.Errorf("MissingCapabilities() of legacy agent is empty")
+	}
+}
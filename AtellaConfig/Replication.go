@@ -0,0 +1,100 @@
+package AtellaConfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Entry, which is ahead of local clock more than this seconds, is
+	// rejected. Otherwise it would win over all next entries of reporter
+	maxMasterClockSkew int64 = 60
+)
+
+// Vector of reporter in master vector with time of receipt. Entries are
+// replicated between masters, newer entry wins. Clocks of masters must be
+// synchronized
+type MasterEntry struct {
+	Timestamp int64        `json:"timestamp"`
+	Vector    []VectorType `json:"vector"`
+}
+
+// Function save vector of reporter into master vector with current time.
+// Return previous vector of reporter and true if it existed
+func (c *Config) SetMasterVector(reporter string,
+	vec []VectorType) ([]VectorType, bool) {
+	c.MasterVectorMutex.Lock()
+	defer c.MasterVectorMutex.Unlock()
+	prev, exist := c.MasterVector[reporter]
+	c.MasterVector[reporter] = vec
+	c.MasterTimestamps[reporter] = time.Now().Unix()
+	return prev, exist
+}
+
+// Function return copy of master vector entries with timestamps
+func (c *Config) GetMasterEntries() map[string]MasterEntry {
+	c.MasterVectorMutex.RLock()
+	defer c.MasterVectorMutex.RUnlock()
+	res := make(map[string]MasterEntry, len(c.MasterVector))
+	for reporter, vector := range c.MasterVector {
+		vec := make([]VectorType, len(vector))
+		for i := range vector {
+			vec[i] = vector[i].Copy()
+		}
+		res[reporter] = MasterEntry{
+			Timestamp: c.MasterTimestamps[reporter],
+			Vector:    vec}
+	}
+	return res
+}
+
+// Function merge entries, received from other master, into master vector.
+// Entry replaces local one only if it is newer. Entries from future are
+// rejected. Return previous entries of reporters, which entries were
+// replaced. Timestamp of previous entry is 0 if reporter was unknown
+func (c *Config) MergeMasterEntries(
+	entries map[string]MasterEntry) map[string]MasterEntry {
+	c.MasterVectorMutex.Lock()
+	defer c.MasterVectorMutex.Unlock()
+	res := make(map[string]MasterEntry)
+	now := time.Now().Unix()
+	for reporter, entry := range entries {
+		if entry.Timestamp > now+maxMasterClockSkew {
+			c.Logger.LogWarning(fmt.Sprintf(
+				"Entry of reporter %s from future [%d] are rejected", reporter,
+				entry.Timestamp))
+			continue
+		}
+		if entry.Timestamp <= c.MasterTimestamps[reporter] {
+			continue
+		}
+		res[reporter] = MasterEntry{
+			Timestamp: c.MasterTimestamps[reporter],
+			Vector:    c.MasterVector[reporter]}
+		c.MasterVector[reporter] = entry.Vector
+		c.MasterTimestamps[reporter] = entry.Timestamp
+	}
+	return res
+}
+
+// Function remove all entries of master vector
+func (c *Config) ResetMasterVector() {
+	c.MasterVectorMutex.Lock()
+	defer c.MasterVectorMutex.Unlock()
+	c.MasterVector = make(map[string][]VectorType, 0)
+	c.MasterTimestamps = make(map[string]int64, 0)
+}
+
+// Function return name of master_servers entry by address or hostname.
+// Name is hostname of entry or address if hostname is not specified
+func (c *Config) MasterName(host string) string {
+	for _, h := range c.MasterServers.Hosts {
+		entry := strings.Split(h, " ")
+		if stringElExists(entry, host) ||
+			stringElExists(strings.Split(entry[0], ","), host) {
+			return entry[len(entry)-1]
+		}
+	}
+	return ""
+}
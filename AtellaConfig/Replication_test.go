@@ -0,0 +1,59 @@
+package AtellaConfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeMasterEntries(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name     string
+		local    int64
+		remote   int64
+		replaced bool
+	}{
+		{"newer entry wins", now - 10, now - 5, true},
+		{"older entry loses", now - 5, now - 10, false},
+		{"same entry loses", now - 5, now - 5, false},
+		{"unknown reporter", 0, now - 5, true},
+		{"small clock skew", now, now + 30, true},
+		{"entry from future", now, now + 3600, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			if tt.local > 0 {
+				c.MasterVector["r"] = []VectorType{{Host: "local"}}
+				c.MasterTimestamps["r"] = tt.local
+			}
+			prev := c.MergeMasterEntries(map[string]MasterEntry{
+				"r": {Timestamp: tt.remote, Vector: []VectorType{{Host: "remote"}}}})
+
+			_, replaced := prev["r"]
+			if replaced != tt.replaced {
+				t.Fatalf("replaced = %v, want %v", replaced, tt.replaced)
+			}
+			want, wantTime := "local", tt.local
+			if tt.replaced {
+				want, wantTime = "remote", tt.remote
+				if prev["r"].Timestamp != tt.local {
+					t.Errorf("previous timestamp = %d, want %d", prev["r"].Timestamp,
+						tt.local)
+				}
+			}
+			if tt.local == 0 && !tt.replaced {
+				want = ""
+			}
+			entries := c.GetMasterEntries()
+			got := ""
+			if len(entries["r"].Vector) > 0 {
+				got = entries["r"].Vector[0].Host
+			}
+			if got != want || entries["r"].Timestamp != wantTime {
+				t.Errorf("entry = %s at %d, want %s at %d", got,
+					entries["r"].Timestamp, want, wantTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package AtellaConfig
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Scheduler of periodic jobs and routines. All of them are cancelled by one
+// context, and Stop waits until they finish
+type Scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Function create scheduler, which is stopped with parent context
+func NewScheduler(parent context.Context) *Scheduler {
+	ctx, cancel := context.WithCancel(parent)
+	return &Scheduler{
+		ctx:    ctx,
+		cancel: cancel}
+}
+
+// Function return context of scheduler
+func (s *Scheduler) Context() context.Context {
+	return s.ctx
+}
+
+// Function return channel, which is closed when scheduler are stopped
+func (s *Scheduler) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Function run routine, which must return after context is done
+func (s *Scheduler) Go(routine func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		routine(s.ctx)
+	}()
+}
+
+// Function run job every interval until scheduler are stopped. First run
+// is after interval. Runs of one job never overlap
+func (s *Scheduler) Every(interval time.Duration, job func(ctx context.Context)) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	s.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job(ctx)
+			}
+		}
+	})
+}
+
+// Function cancel all jobs and wait until they finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Function return interval in seconds as duration
+func Seconds(interval int64) time.Duration {
+	return time.Duration(interval) * time.Second
+}
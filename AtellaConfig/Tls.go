@@ -0,0 +1,149 @@
+package AtellaConfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// Certificate, key and CA, loaded from files on config (re)load
+type tlsFiles struct {
+	mux    sync.Mutex
+	loaded bool
+	cert   tls.Certificate
+	pool   *x509.CertPool
+	err    error
+}
+
+// Function return true if certificate and key are specifyed in security
+// section
+func (c *Config) TLSEnabled() bool {
+	return c.Security.Cert != "" && c.Security.Key != ""
+}
+
+// Function load CA pool from file, specifyed in security section.
+// If CA are not specifyed return nil, so system pool will be used
+func (c *Config) loadCAPool() (*x509.CertPool, error) {
+	if c.Security.CA == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(c.Security.CA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificates found in %s", c.Security.CA)
+	}
+	return pool, nil
+}
+
+// Function read certificate, key and CA from files. TLS files mutex must
+// be locked
+func (c *Config) loadTLSFiles() {
+	c.tls.loaded = true
+	c.tls.cert = tls.Certificate{}
+	c.tls.pool = nil
+	c.tls.err = nil
+	if !c.TLSEnabled() {
+		return
+	}
+	c.tls.cert, c.tls.err = tls.LoadX509KeyPair(c.Security.Cert, c.Security.Key)
+	if c.tls.err != nil {
+		return
+	}
+	c.tls.pool, c.tls.err = c.loadCAPool()
+}
+
+// Function load certificate, key and CA, specifyed in security section.
+// Called on config (re)load, so files are not read on every connection
+func (c *Config) LoadTLS() error {
+	c.tls.mux.Lock()
+	defer c.tls.mux.Unlock()
+	c.loadTLSFiles()
+	return c.tls.err
+}
+
+// Function return loaded certificate and CA pool. Files are loaded at
+// first call, if they were not loaded yet
+func (c *Config) getTLSFiles() (tls.Certificate, *x509.CertPool, error) {
+	c.tls.mux.Lock()
+	defer c.tls.mux.Unlock()
+	if !c.tls.loaded {
+		c.loadTLSFiles()
+	}
+	return c.tls.cert, c.tls.pool, c.tls.err
+}
+
+// Function return TLS config for server side. Clients certificates are
+// required and verified if CA is specifyed
+func (c *Config) GetServerTLSConfig() (*tls.Config, error) {
+	cert, pool, err := c.getTLSFiles()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12}
+	if pool != nil {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Function return TLS config for client side. Server certificate are
+// verified for serverName
+func (c *Config) GetClientTLSConfig(serverName string) (*tls.Config, error) {
+	cert, pool, err := c.getTLSFiles()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12}, nil
+}
+
+// Function open connection to agent protocol on host. If TLS enabled,
+// connection are encrypted and peer are verified
+func (c *Config) Dial(host string, port int16) (net.Conn, error) {
+	return c.DialContext(context.Background(), host, port)
+}
+
+// Function open connection like Dial, but peer certificate are verified
+// for serverName, e.g. hostname of agent, which is dialed by address
+func (c *Config) DialName(host string, serverName string,
+	port int16) (net.Conn, error) {
+	return c.dial(context.Background(), host, serverName, port)
+}
+
+// Function open connection like Dial, dialing are cancelled with context
+func (c *Config) DialContext(ctx context.Context, host string,
+	port int16) (net.Conn, error) {
+	return c.dial(ctx, host, host, port)
+}
+
+func (c *Config) dial(ctx context.Context, host string, serverName string,
+	port int16) (net.Conn, error) {
+	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	dialer := &net.Dialer{
+		Timeout: time.Duration(c.Agent.NetTimeout) * time.Second}
+	if !c.TLSEnabled() {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+	config, err := c.GetClientTLSConfig(serverName)
+	if err != nil {
+		return nil, err
+	}
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    config}
+	return tlsDialer.DialContext(ctx, "tcp", address)
+}
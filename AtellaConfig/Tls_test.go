@@ -0,0 +1,138 @@
+package AtellaConfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Function write self-signed certificate for hostname, its key and CA
+// into dir and return config, which uses them
+func newTLSConfig(t *testing.T, dir string, hostname string) *Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: hostname},
+		DNSNames:              []string{hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "atella.crt")
+	keyFile := filepath.Join(dir, "atella.key")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(certFile, certPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.Security.Cert = certFile
+	c.Security.Key = keyFile
+	c.Security.CA = certFile
+	return c
+}
+
+func TestLoadTLS(t *testing.T) {
+	dir := t.TempDir()
+	c := newTLSConfig(t, dir, "agent.test")
+	if err := c.LoadTLS(); err != nil {
+		t.Fatalf("LoadTLS() error: %s", err)
+	}
+	// Loaded files are used until next load
+	c.Security.Cert = filepath.Join(dir, "none.crt")
+	if _, err := c.GetServerTLSConfig(); err != nil {
+		t.Errorf("GetServerTLSConfig() reads files after load: %s", err)
+	}
+	if err := c.LoadTLS(); err == nil {
+		t.Errorf("LoadTLS() of missing certificate succeeded")
+	}
+
+	c = NewConfig()
+	if err := c.LoadTLS(); err != nil || c.TLSEnabled() {
+		t.Errorf("LoadTLS() without files = %v, enabled %v", err, c.TLSEnabled())
+	}
+}
+
+func TestDialName(t *testing.T) {
+	c := newTLSConfig(t, t.TempDir(), "agent.test")
+	c.Agent.NetTimeout = 2
+	config, err := c.GetServerTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Port of dial is int16, so ephemeral ports don't fit
+	var (
+		ln   net.Listener
+		port int
+	)
+	for port = 20100; port < 20200; port = port + 1 {
+		if ln, err = tls.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port),
+			config); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		serverName string
+		ok         bool
+	}{
+		{"hostname of certificate", "agent.test", true},
+		{"address", "127.0.0.1", false},
+		{"other hostname", "other.test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := c.DialName("127.0.0.1", tt.serverName, int16(port))
+			if err == nil {
+				err = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("DialName() error = %v, want success %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package AtellaConfig
+
+import (
+	"encoding/json"
+	"sync"
+
+	"../AtellaCheck"
+)
+
+// Vector of neighbours, indexed by host. Safe for concurrent use: elements
+// are returned and saved by copy, so callers never share memory with store
+type VectorStore struct {
+	mux   sync.RWMutex
+	items []VectorType
+	index map[string]int
+}
+
+// Function create empty vector store
+func NewVectorStore() *VectorStore {
+	return &VectorStore{
+		items: make([]VectorType, 0),
+		index: make(map[string]int)}
+}
+
+// Function return deep copy of vector element
+func (vec VectorType) Copy() VectorType {
+	res := vec
+	res.Sectors = copyStrings(vec.Sectors)
+	res.Capabilities = copyStrings(vec.Capabilities)
+	res.Missing = copyStrings(vec.Missing)
+	res.DialTime = vec.DialTime.Copy()
+	res.Rtt = vec.Rtt.Copy()
+	res.Info = vec.Info.Copy()
+	if vec.Problems != nil {
+		res.Problems = make([]Problem, len(vec.Problems))
+		copy(res.Problems, vec.Problems)
+	}
+	if vec.Checks != nil {
+		res.Checks = make([]AtellaCheck.Result, len(vec.Checks))
+		copy(res.Checks, vec.Checks)
+	}
+	if vec.changes != nil {
+		res.changes = make([]int64, len(vec.changes))
+		copy(res.changes, vec.changes)
+	}
+	return res
+}
+
+// Function return copy of string array. Nil array stays nil
+func copyStrings(array []string) []string {
+	if array == nil {
+		return nil
+	}
+	res := make([]string, len(array))
+	copy(res, array)
+	return res
+}
+
+// Function return copy of vector element by host and true if element exist
+func (v *VectorStore) Get(host string) (VectorType, bool) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	i, exist := v.index[host]
+	if !exist {
+		return VectorType{}, false
+	}
+	return v.items[i].Copy(), true
+}
+
+// Function save vector element. Existing element with same host are
+// overridden, else element are appended
+func (v *VectorStore) Set(vec VectorType) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if i, exist := v.index[vec.Host]; exist {
+		v.items[i] = vec.Copy()
+		return
+	}
+	v.index[vec.Host] = len(v.items)
+	v.items = append(v.items, vec.Copy())
+}
+
+// Function atomically modify vector element by host. Return false if
+// element doesn.t exist
+func (v *VectorStore) Update(host string, f func(vec *VectorType)) bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	i, exist := v.index[host]
+	if !exist {
+		return false
+	}
+	vec := v.items[i].Copy()
+	f(&vec)
+	vec.Host = host
+	v.items[i] = vec
+	return true
+}
+
+// Function return copy of all vector elements in order of adding
+func (v *VectorStore) Snapshot() []VectorType {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	res := make([]VectorType, len(v.items))
+	for i := range v.items {
+		res[i] = v.items[i].Copy()
+	}
+	return res
+}
+
+// Function return count of vector elements
+func (v *VectorStore) Len() int {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	return len(v.items)
+}
+
+// Function remove all vector elements
+func (v *VectorStore) Reset() {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.items = make([]VectorType, 0)
+	v.index = make(map[string]int)
+}
+
+// Function return vector as json format
+func (v *VectorStore) MarshalJSON() ([]byte, error) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	return json.Marshal(v.items)
+}
@@ -0,0 +1,63 @@
+package AtellaConfig
+
+import (
+	"testing"
+)
+
+func TestVectorStore(t *testing.T) {
+	v := NewVectorStore()
+	v.Set(VectorType{Host: "a", Sectors: []string{"s1"}})
+	v.Set(VectorType{Host: "b"})
+	v.Set(VectorType{Host: "a", Hostname: "alpha", Sectors: []string{"s2"}})
+
+	if v.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", v.Len())
+	}
+	snapshot := v.Snapshot()
+	if snapshot[0].Host != "a" || snapshot[1].Host != "b" {
+		t.Errorf("Snapshot() order = %s, %s, want a, b", snapshot[0].Host,
+			snapshot[1].Host)
+	}
+	if snapshot[0].Hostname != "alpha" {
+		t.Errorf("Set() didn't override element: %+v", snapshot[0])
+	}
+
+	// Returned elements don't share memory with store
+	vec, ok := v.Get("a")
+	if !ok {
+		t.Fatalf("Get() of existing host failed")
+	}
+	vec.Sectors[0] = "changed"
+	snapshot[0].Sectors[0] = "changed"
+	if vec, _ := v.Get("a"); vec.Sectors[0] != "s2" {
+		t.Errorf("store was changed by copy: %v", vec.Sectors)
+	}
+	if _, ok := v.Get("none"); ok {
+		t.Errorf("Get() of unknown host succeeded")
+	}
+
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"a", true},
+		{"none", false},
+	}
+	for _, tt := range tests {
+		ok := v.Update(tt.host, func(vec *VectorType) {
+			vec.Host = "renamed"
+			vec.Status = true
+		})
+		if ok != tt.ok {
+			t.Errorf("Update(%q) = %v, want %v", tt.host, ok, tt.ok)
+		}
+	}
+	if vec, _ := v.Get("a"); !vec.Status || vec.Host != "a" {
+		t.Errorf("Update() result = %+v, want status on host a", vec)
+	}
+
+	v.Reset()
+	if v.Len() != 0 {
+		t.Errorf("Len() after Reset() = %d", v.Len())
+	}
+}
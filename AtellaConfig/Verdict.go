@@ -0,0 +1,137 @@
+package AtellaConfig
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	VerdictUp       string = "up"
+	VerdictDown     string = "down"
+	VerdictDegraded string = "degraded"
+	VerdictUnknown  string = "unknown"
+)
+
+// Aggregated opinion of reporters about one host
+type VerdictType struct {
+	Host      string   `json:"host"`
+	Hostname  string   `json:"hostname"`
+	Verdict   string   `json:"verdict"`
+	Reporters []string `json:"reporters"`
+	Down      []string `json:"down"`
+	Sectors   []string `json:"sectors"`
+	// Round-trip time, measured by reporters, which see the host up
+	Rtt LatencyStats `json:"rtt"`
+}
+
+// Function return true if vector element was probed at least once
+func (v *VectorType) IsProbed() bool {
+	return v.Status || v.Hostname != "unknown" || v.Stage != ""
+}
+
+// Function compute verdict by count of reporters and count of reporters,
+// which see the host down. Quorum is a percent of reporters
+func GetVerdict(reporters int, down int, quorum int64) string {
+	if reporters < 1 {
+		return VerdictUnknown
+	}
+	if down == 0 {
+		return VerdictUp
+	}
+	if int64(down)*100 >= quorum*int64(reporters) {
+		return VerdictDown
+	}
+	return VerdictDegraded
+}
+
+// Function return true if reporter didn.t send vector during
+// reporter_expire intervals. Master vector mutex must be locked
+func (c *Config) isReporterExpired(reporter string, now int64) bool {
+	if c.Agent.ReporterExpire < 1 {
+		return false
+	}
+	return now-c.MasterTimestamps[reporter] >
+		c.Agent.ReporterExpire*c.Agent.Interval
+}
+
+// Function aggregate MasterVector by target hosts and return verdicts.
+// Expired reporters are ignored
+func (c *Config) GetMasterVerdicts() map[string]*VerdictType {
+	verdicts := make(map[string]*VerdictType, 0)
+	now := time.Now().Unix()
+
+	c.MasterVectorMutex.RLock()
+	for reporter, vector := range c.MasterVector {
+		if c.isReporterExpired(reporter, now) {
+			continue
+		}
+		for _, vec := range vector {
+			v, ok := verdicts[vec.Host]
+			if !ok {
+				v = &VerdictType{
+					Host:      vec.Host,
+					Hostname:  "unknown",
+					Verdict:   VerdictUnknown,
+					Reporters: make([]string, 0),
+					Down:      make([]string, 0),
+					Sectors:   make([]string, 0)}
+				verdicts[vec.Host] = v
+			}
+			for _, sector := range vec.Sectors {
+				if !stringElExists(v.Sectors, sector) {
+					v.Sectors = append(v.Sectors, sector)
+				}
+			}
+			if !vec.IsProbed() {
+				continue
+			}
+			if v.Hostname == "unknown" {
+				v.Hostname = vec.Hostname
+			}
+			v.Reporters = append(v.Reporters, reporter)
+			if !vec.Status {
+				v.Down = append(v.Down, reporter)
+			} else {
+				v.Rtt.Merge(vec.Rtt)
+			}
+		}
+	}
+	c.MasterVectorMutex.RUnlock()
+
+	for _, v := range verdicts {
+		v.Verdict = GetVerdict(len(v.Reporters), len(v.Down), c.Agent.Quorum)
+	}
+	return verdicts
+}
+
+// Function return verdict for host. If nobody reports about host,
+// verdict is unknown
+func (c *Config) GetMasterVerdict(host string) *VerdictType {
+	verdicts := c.GetMasterVerdicts()
+	if v, ok := verdicts[host]; ok {
+		return v
+	}
+	return &VerdictType{
+		Host:      host,
+		Hostname:  "unknown",
+		Verdict:   VerdictUnknown,
+		Reporters: make([]string, 0),
+		Down:      make([]string, 0),
+		Sectors:   make([]string, 0)}
+}
+
+// Function return master verdicts as json format
+func (c *Config) GetJsonMasterVerdicts() []byte {
+	res, _ := json.Marshal(c.GetMasterVerdicts())
+	return res
+}
+
+// Function check string array and return true if item exist
+func stringElExists(array []string, item string) bool {
+	for i := 0; i < len(array); i = i + 1 {
+		if array[i] == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package AtellaConfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetVerdict(t *testing.T) {
+	tests := []struct {
+		name      string
+		reporters int
+		down      int
+		quorum    int64
+		want      string
+	}{
+		{"nobody reports", 0, 0, 50, VerdictUnknown},
+		{"all see up", 3, 0, 50, VerdictUp},
+		{"minority sees down", 3, 1, 50, VerdictDegraded},
+		{"half is quorum", 4, 2, 50, VerdictDown},
+		{"all see down", 2, 2, 50, VerdictDown},
+		{"zero quorum", 5, 1, 0, VerdictDown},
+		{"full quorum", 5, 4, 100, VerdictDegraded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetVerdict(tt.reporters, tt.down, tt.quorum); got != tt.want {
+				t.Errorf("GetVerdict(%d, %d, %d) = %s, want %s", tt.reporters,
+					tt.down, tt.quorum, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMasterVerdicts(t *testing.T) {
+	c := NewConfig()
+	c.Agent.Interval = 10
+	c.Agent.ReporterExpire = 3
+	now := time.Now().Unix()
+	up := VectorType{Host: "h", Hostname: "target", Status: true}
+	down := VectorType{Host: "h", Hostname: "target", Status: false}
+	c.MasterVector["r1"] = []VectorType{up}
+	c.MasterVector["r2"] = []VectorType{down}
+	c.MasterVector["old"] = []VectorType{down}
+	c.MasterTimestamps["r1"] = now
+	c.MasterTimestamps["r2"] = now
+	c.MasterTimestamps["old"] = now - 31
+
+	v := c.GetMasterVerdict("h")
+	if v.Verdict != VerdictDown || len(v.Reporters) != 2 || len(v.Down) != 1 {
+		t.Errorf("GetMasterVerdict() = %s by %v, down %v, want down by 2",
+			v.Verdict, v.Reporters, v.Down)
+	}
+	if v := c.GetMasterVerdict("none"); v.Verdict != VerdictUnknown {
+		t.Errorf("GetMasterVerdict() of unknown host = %s", v.Verdict)
+	}
+
+	c.Agent.ReporterExpire = 0
+	if v := c.GetMasterVerdict("h"); len(v.Reporters) != 3 {
+		t.Errorf("GetMasterVerdict() without expire has %d reporters, want 3",
+			len(v.Reporters))
+	}
+}
@@ -1,4 +1,115 @@
 package AtellaGraphiteChannel
 
-// TODO: Send metric via tcp/udp
-// (Metric may be specified as a message in other channels)
\ No newline at end of file
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Graphite Channel configuration. Exportable and used in Agent Config
+type AtellaGraphiteConfig struct {
+	Address    string `json:"address"`
+	Port       int16  `json:"port"`
+	Protocol   string `json:"protocol"`
+	Prefix     string `json:"prefix"`
+	Disabled   bool   `json:"disabled"`
+	NetTimeout int
+}
+
+// One point of Graphite plaintext protocol.
+type Metric struct {
+	Path      string
+	Value     float64
+	Timestamp int64
+}
+
+var (
+	pathEscaper = strings.NewReplacer(
+		".", "_",
+		" ", "_",
+		"/", "_",
+		";", "_",
+		"~", "_")
+)
+
+// Function return string, suitable to be a single node of metric path.
+func EscapeNode(node string) string {
+	if node == "" {
+		return "unknown"
+	}
+	return pathEscaper.Replace(node)
+}
+
+// Function build metric path from nodes with configured prefix.
+func (config *AtellaGraphiteConfig) Path(nodes ...string) string {
+	path := make([]string, 0)
+	if config.Prefix != "" {
+		path = append(path, config.Prefix)
+	}
+	for _, node := range nodes {
+		path = append(path, EscapeNode(node))
+	}
+	return strings.Join(path, ".")
+}
+
+// Function initialize send message (text) via Graphite Channel. Plaintext
+// protocol can't carry text, so the report is sent as event counter of the
+// host. It is exportable function
+func (config *AtellaGraphiteConfig) SendMessage(text string, hostname string) (bool,
+	error) {
+	if config.Disabled {
+		return false, nil
+	}
+	event := Metric{
+		Path:      config.Path(hostname, "events", "report"),
+		Value:     1,
+		Timestamp: time.Now().Unix()}
+	err := config.SendMetrics([]Metric{event})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Function send metrics via Graphite Channel in plaintext protocol.
+// It is exportable function
+func (config *AtellaGraphiteConfig) SendMetrics(metrics []Metric) error {
+	if config.Disabled || len(metrics) < 1 {
+		return nil
+	}
+	protocol := strings.ToLower(config.Protocol)
+	if protocol != "tcp" && protocol != "udp" {
+		return fmt.Errorf("Unsupported graphite protocol %s", config.Protocol)
+	}
+	conn, err := net.DialTimeout(protocol,
+		fmt.Sprintf("%s:%d", config.Address, config.Port),
+		time.Duration(config.NetTimeout)*time.Second)
+	if err != nil {
+		return fmt.Errorf("%s", err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(
+		time.Duration(config.NetTimeout) * time.Second))
+
+	// Each UDP datagram must contain only whole lines, so write metrics
+	// one by one. TCP stream can take all of them at once.
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		line := fmt.Sprintf("%s %g %d\n", m.Path, m.Value, m.Timestamp)
+		if protocol == "udp" {
+			if _, err = conn.Write([]byte(line)); err != nil {
+				return fmt.Errorf("%s", err)
+			}
+			continue
+		}
+		buf.WriteString(line)
+	}
+	if protocol == "tcp" {
+		if _, err = conn.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("%s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package AtellaGraphiteChannel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestEscapeNode(t *testing.T) {
+	tests := []struct {
+		node string
+		want string
+	}{
+		{"host", "host"},
+		{"", "unknown"},
+		{"host.example.com", "host_example_com"},
+		{"/var/log", "_var_log"},
+		{"a b;c~d", "a_b_c_d"},
+		{"10.0.0.1", "10_0_0_1"},
+	}
+	for _, tt := range tests {
+		if got := EscapeNode(tt.node); got != tt.want {
+			t.Errorf("EscapeNode(%q) = %q, want %q", tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		prefix string
+		nodes  []string
+		want   string
+	}{
+		{"", []string{"host", "status"}, "host.status"},
+		{"atella", []string{"host.example.com", "status"},
+			"atella.host_example_com.status"},
+		{"atella.prod", []string{"h", "info", "disk", "/"},
+			"atella.prod.h.info.disk._"},
+		{"atella", []string{"", "status"}, "atella.unknown.status"},
+	}
+	for _, tt := range tests {
+		config := &AtellaGraphiteConfig{Prefix: tt.prefix}
+		if got := config.Path(tt.nodes...); got != tt.want {
+			t.Errorf("Path(%q, %v) = %q, want %q", tt.prefix, tt.nodes, got,
+				tt.want)
+		}
+	}
+}
+
+func TestSendMetrics(t *testing.T) {
+	// Port of config is int16, so ephemeral ports don't fit
+	var (
+		ln   net.Listener
+		err  error
+		port int
+	)
+	for port = 20000; port < 20100; port = port + 1 {
+		if ln, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		res := make([]string, 0)
+		conn, err := ln.Accept()
+		if err == nil {
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				res = append(res, scanner.Text())
+			}
+			conn.Close()
+		}
+		lines <- res
+	}()
+
+	config := &AtellaGraphiteConfig{
+		Address:    "127.0.0.1",
+		Port:       int16(port),
+		Protocol:   "TCP",
+		NetTimeout: 2}
+	err = config.SendMetrics([]Metric{
+		{Path: "a.status", Value: 1, Timestamp: 100},
+		{Path: "a.latency", Value: 1.5, Timestamp: 100}})
+	if err != nil {
+		t.Fatalf("SendMetrics() error: %s", err)
+	}
+	got := <-lines
+	want := []string{"a.status 1 100", "a.latency 1.5 100"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("SendMetrics() sent %q, want %q", got, want)
+	}
+
+	config.Protocol = "http"
+	if err := config.SendMetrics([]Metric{{Path: "a"}}); err == nil {
+		t.Errorf("SendMetrics() with unsupported protocol succeeded")
+	}
+}
@@ -0,0 +1,169 @@
+package AtellaHttp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"../AtellaConfig"
+)
+
+// HTTP API server parameters
+type AtellaHttp struct {
+	configuration *AtellaConfig.Config
+	server        *http.Server
+}
+
+// Create new HTTP API server. Address are taken from agent section
+func New(c *AtellaConfig.Config) *AtellaHttp {
+	h := &AtellaHttp{
+		configuration: c,
+		server:        nil}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/vector", h.handle(h.vector))
+	mux.HandleFunc("/api/master", h.handle(h.master))
+	mux.HandleFunc("/api/verdict", h.handle(h.verdict))
+	mux.HandleFunc("/api/leader", h.handle(h.leader))
+	mux.HandleFunc("/api/config", h.handle(h.config))
+	mux.HandleFunc("/api/messages", h.handle(h.messages))
+	mux.HandleFunc("/api/version", h.handle(h.version))
+	mux.HandleFunc("/metrics", h.metrics)
+	h.server = &http.Server{
+		Addr:         c.Agent.HttpAddress,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(c.Agent.NetTimeout) * time.Second,
+		WriteTimeout: time.Duration(c.Agent.NetTimeout) * time.Second}
+	return h
+}
+
+// Listen for HTTP requests. If address are not specifyed, listener
+// are disabled
+func (h *AtellaHttp) Listen() {
+	var err error
+
+	if h.configuration.Agent.HttpAddress == "" {
+		h.configuration.Logger.LogSystem("[Http] HTTP API disabled")
+		return
+	}
+	h.configuration.Logger.LogSystem(fmt.Sprintf("[Http] Init HTTP API with address %s",
+		h.configuration.Agent.HttpAddress))
+
+	if h.configuration.TLSEnabled() {
+		config, err := h.configuration.GetServerTLSConfig()
+		if err != nil {
+			h.configuration.Logger.LogFatal(fmt.Sprintf("[Http] Error loading TLS config. %s", err))
+		}
+		// Dashboards and scripts authenticate by token
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		h.server.TLSConfig = config
+		err = h.server.ListenAndServeTLS("", "")
+	} else {
+		err = h.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		h.configuration.Logger.LogError(fmt.Sprintf("[Http] Error starting HTTP API. %s", err))
+	}
+}
+
+// Function for stopping HTTP API server
+func (h *AtellaHttp) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(h.configuration.Agent.NetTimeout)*time.Second)
+	defer cancel()
+	h.server.Shutdown(ctx)
+	h.configuration.Logger.LogSystem("[Http] HTTP API stopped")
+}
+
+// Function check method and token of request. Token are sent as
+// "Authorization: Bearer {token}" and must have read role.
+// Return false if reply already written
+func (h *AtellaHttp) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		h.reply(w, http.StatusMethodNotAllowed, nil, fmt.Errorf("method not allowed"))
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	name, role := h.configuration.GetCodeRole(token)
+	if role == "" {
+		h.configuration.Logger.LogError(fmt.Sprintf(
+			"[Http] Unauthorized request %s from %s", r.URL.Path, r.RemoteAddr))
+		h.reply(w, http.StatusUnauthorized, nil, fmt.Errorf("unauthorized"))
+		return false
+	}
+	if !AtellaConfig.RoleAllows(role, AtellaConfig.RoleRead) {
+		h.configuration.Logger.LogError(fmt.Sprintf(
+			"[Http] Forbidden request %s for role %s from %s", r.URL.Path,
+			role, r.RemoteAddr))
+		h.reply(w, http.StatusForbidden, nil, fmt.Errorf("forbidden"))
+		return false
+	}
+	h.configuration.Logger.LogInfo(fmt.Sprintf("[Http] Request %s from %s [%s]",
+		r.URL.Path, r.RemoteAddr, name))
+	return true
+}
+
+// Function wrap JSON handler by method and token checks
+func (h *AtellaHttp) handle(f func() (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.authorize(w, r) {
+			return
+		}
+		res, err := f()
+		if err != nil {
+			h.reply(w, http.StatusInternalServerError, nil, err)
+			return
+		}
+		h.reply(w, http.StatusOK, res, nil)
+	}
+}
+
+// Function write JSON reply
+func (h *AtellaHttp) reply(w http.ResponseWriter, code int, res interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err != nil {
+		res = map[string]string{"error": err.Error()}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *AtellaHttp) vector() (interface{}, error) {
+	return json.RawMessage(h.configuration.GetJsonVector()), nil
+}
+
+func (h *AtellaHttp) master() (interface{}, error) {
+	return json.RawMessage(h.configuration.GetJsonMasterVector()), nil
+}
+
+func (h *AtellaHttp) verdict() (interface{}, error) {
+	return json.RawMessage(h.configuration.GetJsonMasterVerdicts()), nil
+}
+
+func (h *AtellaHttp) leader() (interface{}, error) {
+	return h.configuration.GetLeaderStatus(), nil
+}
+
+func (h *AtellaHttp) config() (interface{}, error) {
+	return json.RawMessage(h.configuration.GetJsonRedactedConfig()), nil
+}
+
+func (h *AtellaHttp) messages() (interface{}, error) {
+	return h.configuration.GetMessages()
+}
+
+func (h *AtellaHttp) version() (interface{}, error) {
+	return map[string]interface{}{
+		"service":          AtellaConfig.Service,
+		"version":          AtellaConfig.Version,
+		"git_commit":       AtellaConfig.GitCommit,
+		"go_version":       AtellaConfig.GoVersion,
+		"arch":             AtellaConfig.Arch,
+		"sys":              AtellaConfig.Sys,
+		"protocol_version": AtellaConfig.ProtocolVersion,
+		"hostname":         h.configuration.Agent.Hostname,
+		"master":           h.configuration.Agent.Master}, nil
+}
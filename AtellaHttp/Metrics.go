@@ -0,0 +1,332 @@
+package AtellaHttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"../AtellaConfig"
+)
+
+var (
+	labelEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`)
+)
+
+// Prometheus text format writer
+type exposition struct {
+	buf bytes.Buffer
+}
+
+// Function write HELP and TYPE lines of metric family
+func (e *exposition) family(name string, kind string, help string) {
+	fmt.Fprintf(&e.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&e.buf, "# TYPE %s %s\n", name, kind)
+}
+
+// Function write sample. Labels are pairs of name and value
+func (e *exposition) sample(name string, value float64, labels ...string) {
+	pairs := make([]string, 0)
+	for i := 0; i+1 < len(labels); i = i + 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i],
+			labelEscaper.Replace(labels[i+1])))
+	}
+	if len(pairs) > 0 {
+		fmt.Fprintf(&e.buf, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+	} else {
+		fmt.Fprintf(&e.buf, "%s %g\n", name, value)
+	}
+}
+
+// Function write min, avg and max of latency statistics as samples with
+// stat label. Statistics without samples are skipped
+func (e *exposition) latency(name string, stats AtellaConfig.LatencyStats,
+	labels ...string) {
+	if stats.Count < 1 {
+		return
+	}
+	e.sample(name, stats.Min, append(labels, "stat", "min")...)
+	e.sample(name, stats.Avg, append(labels, "stat", "avg")...)
+	e.sample(name, stats.Max, append(labels, "stat", "max")...)
+}
+
+// Function convert bool status into metric value
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Handler of /metrics in Prometheus text format
+func (h *AtellaHttp) metrics(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r) {
+		return
+	}
+	e := &exposition{}
+	h.writeVectorMetrics(e)
+	if h.configuration.Agent.Master {
+		h.writeMasterMetrics(e)
+	}
+	h.writeSenderMetrics(e)
+	h.writeDeliveryMetrics(e)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.buf.Bytes())
+}
+
+// Function write metrics of local vector
+func (h *AtellaHttp) writeVectorMetrics(e *exposition) {
+	vector := h.configuration.Vector.Snapshot()
+
+	e.family("atella_neighbour_up", "gauge",
+		"Status of neighbour by last probe (1 - up, 0 - down).")
+	for _, vec := range vector {
+		for _, sector := range vec.Sectors {
+			e.sample("atella_neighbour_up", boolValue(vec.Status),
+				"host", vec.Host, "hostname", vec.Hostname, "sector", sector)
+		}
+	}
+
+	e.family("atella_neighbour_last_probe_timestamp_seconds", "gauge",
+		"Time of last probe of neighbour.")
+	for _, vec := range vector {
+		e.sample("atella_neighbour_last_probe_timestamp_seconds",
+			float64(vec.Timestamp), "host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_neighbour_last_seen_timestamp_seconds", "gauge",
+		"Time of last successful probe of neighbour.")
+	for _, vec := range vector {
+		e.sample("atella_neighbour_last_seen_timestamp_seconds",
+			float64(vec.LastSeen), "host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_neighbour_rtt_milliseconds", "gauge",
+		"Handshake round-trip time of neighbour over sliding window.")
+	for _, vec := range vector {
+		e.latency("atella_neighbour_rtt_milliseconds", vec.Rtt,
+			"host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_neighbour_dial_milliseconds", "gauge",
+		"Connection time of neighbour over sliding window.")
+	for _, vec := range vector {
+		e.latency("atella_neighbour_dial_milliseconds", vec.DialTime,
+			"host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_check_up", "gauge",
+		"Result of sector check of neighbour (1 - success, 0 - failure).")
+	for _, vec := range vector {
+		for _, check := range vec.Checks {
+			e.sample("atella_check_up", boolValue(check.Status), "host", vec.Host,
+				"hostname", vec.Hostname, "check", check.Name, "type", check.Type)
+		}
+	}
+
+	e.family("atella_check_duration_milliseconds", "gauge",
+		"Duration of last sector check of neighbour.")
+	for _, vec := range vector {
+		for _, check := range vec.Checks {
+			e.sample("atella_check_duration_milliseconds", check.Duration,
+				"host", vec.Host, "hostname", vec.Hostname, "check", check.Name,
+				"type", check.Type)
+		}
+	}
+
+	e.family("atella_neighbour_flapping", "gauge",
+		"Neighbour changes status too often (1 - flapping).")
+	for _, vec := range vector {
+		e.sample("atella_neighbour_flapping", boolValue(vec.Flapping),
+			"host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_neighbour_transitions_total", "counter",
+		"Count of neighbour status changes.")
+	for _, vec := range vector {
+		e.sample("atella_neighbour_transitions_total", float64(vec.Transitions),
+			"host", vec.Host, "hostname", vec.Hostname)
+	}
+
+	e.family("atella_neighbour_load1", "gauge",
+		"Load average for 1 minute of neighbour.")
+	for _, vec := range vector {
+		if vec.Info != nil {
+			e.sample("atella_neighbour_load1", vec.Info.Load1,
+				"host", vec.Host, "hostname", vec.Hostname)
+		}
+	}
+
+	e.family("atella_neighbour_memory_used_percent", "gauge",
+		"Used memory of neighbour in percents.")
+	for _, vec := range vector {
+		if vec.Info != nil {
+			e.sample("atella_neighbour_memory_used_percent", vec.Info.MemPercent,
+				"host", vec.Host, "hostname", vec.Hostname)
+		}
+	}
+
+	e.family("atella_neighbour_disk_used_percent", "gauge",
+		"Used disk space of neighbour mount point in percents.")
+	for _, vec := range vector {
+		if vec.Info == nil {
+			continue
+		}
+		for _, d := range vec.Info.Disks {
+			e.sample("atella_neighbour_disk_used_percent", d.Percent,
+				"host", vec.Host, "hostname", vec.Hostname, "mount", d.Mount)
+		}
+	}
+
+	e.family("atella_neighbour_latency_milliseconds", "gauge",
+		"Duration of last successful probe of neighbour.")
+	for _, vec := range vector {
+		e.sample("atella_neighbour_latency_milliseconds", vec.Latency,
+			"host", vec.Host, "hostname", vec.Hostname)
+	}
+}
+
+// Function write metrics of master vector and verdicts
+func (h *AtellaHttp) writeMasterMetrics(e *exposition) {
+	h.configuration.MasterVectorMutex.RLock()
+	reporters := make([]string, 0)
+	for reporter := range h.configuration.MasterVector {
+		reporters = append(reporters, reporter)
+	}
+	sort.Strings(reporters)
+
+	e.family("atella_master_reporter_up", "gauge",
+		"Status of host, reported by neighbour (1 - up, 0 - down).")
+	for _, reporter := range reporters {
+		for _, vec := range h.configuration.MasterVector[reporter] {
+			e.sample("atella_master_reporter_up", boolValue(vec.Status),
+				"reporter", reporter, "host", vec.Host, "hostname", vec.Hostname)
+		}
+	}
+
+	e.family("atella_master_reporter_last_probe_timestamp_seconds", "gauge",
+		"Time of last probe of host, reported by neighbour.")
+	for _, reporter := range reporters {
+		for _, vec := range h.configuration.MasterVector[reporter] {
+			e.sample("atella_master_reporter_last_probe_timestamp_seconds",
+				float64(vec.Timestamp), "reporter", reporter, "host", vec.Host,
+				"hostname", vec.Hostname)
+		}
+	}
+
+	e.family("atella_master_reporter_rtt_milliseconds", "gauge",
+		"Handshake round-trip time of host, reported by neighbour.")
+	for _, reporter := range reporters {
+		for _, vec := range h.configuration.MasterVector[reporter] {
+			e.latency("atella_master_reporter_rtt_milliseconds", vec.Rtt,
+				"reporter", reporter, "host", vec.Host, "hostname", vec.Hostname)
+		}
+	}
+	h.configuration.MasterVectorMutex.RUnlock()
+
+	verdicts := h.configuration.GetMasterVerdicts()
+	e.family("atella_master_verdict", "gauge",
+		"Current quorum verdict about host.")
+	for _, v := range verdicts {
+		e.sample("atella_master_verdict", 1, "host", v.Host,
+			"hostname", v.Hostname, "verdict", v.Verdict)
+	}
+
+	e.family("atella_master_rtt_milliseconds", "gauge",
+		"Handshake round-trip time of host, aggregated over reporters.")
+	for _, v := range verdicts {
+		e.latency("atella_master_rtt_milliseconds", v.Rtt, "host", v.Host,
+			"hostname", v.Hostname)
+	}
+
+	status := h.configuration.GetLeaderStatus()
+	e.family("atella_master_leader", "gauge",
+		"Local master is leader of masters (1 - leader, 0 - standby).")
+	e.sample("atella_master_leader", boolValue(status.IsLeader),
+		"leader", status.Leader)
+
+	e.family("atella_master_alive", "gauge",
+		"Master was seen during lease (1 - alive, 0 - not seen).")
+	for _, m := range status.Masters {
+		e.sample("atella_master_alive", boolValue(m.Alive), "master", m.Host)
+	}
+}
+
+// Function write health of vector delivery to masters
+func (h *AtellaHttp) writeDeliveryMetrics(e *exposition) {
+	stats := h.configuration.GetDeliveryStats()
+	masters := make([]string, 0)
+	for master := range stats {
+		masters = append(masters, master)
+	}
+	sort.Strings(masters)
+
+	e.family("atella_master_delivery_total", "counter",
+		"Count of vector delivery attempts to master.")
+	for _, master := range masters {
+		e.sample("atella_master_delivery_total", float64(stats[master].Success),
+			"master", master, "result", "success")
+		e.sample("atella_master_delivery_total", float64(stats[master].Failure),
+			"master", master, "result", "failure")
+	}
+
+	e.family("atella_master_delivery_consecutive_failures", "gauge",
+		"Count of failed vector deliveries to master since last success.")
+	for _, master := range masters {
+		e.sample("atella_master_delivery_consecutive_failures",
+			float64(stats[master].ConsecutiveFailures), "master", master)
+	}
+
+	e.family("atella_master_delivery_last_success_timestamp_seconds", "gauge",
+		"Time of last successful vector delivery to master.")
+	for _, master := range masters {
+		e.sample("atella_master_delivery_last_success_timestamp_seconds",
+			float64(stats[master].LastSuccess), "master", master)
+	}
+
+	e.family("atella_master_delivery_milliseconds", "gauge",
+		"Duration of vector delivery to master over sliding window.")
+	for _, master := range masters {
+		e.latency("atella_master_delivery_milliseconds", stats[master].Latency,
+			"master", master)
+	}
+
+	e.family("atella_master_delivery_score", "gauge",
+		"Score of master, used for master selection.")
+	for _, master := range masters {
+		e.sample("atella_master_delivery_score", stats[master].Score,
+			"master", master)
+	}
+}
+
+// Function write metrics of spool and channels
+func (h *AtellaHttp) writeSenderMetrics(e *exposition) {
+	messages, err := h.configuration.GetMessages()
+	if err == nil {
+		e.family("atella_spool_messages", "gauge",
+			"Count of messages in spool, waiting for sending.")
+		e.sample("atella_spool_messages", float64(len(messages)))
+	}
+
+	stats := h.configuration.GetChannelStats()
+	channels := make([]string, 0)
+	for channel := range stats {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	e.family("atella_channel_send_total", "counter",
+		"Count of messages sending attempts via channel.")
+	for _, channel := range channels {
+		e.sample("atella_channel_send_total", float64(stats[channel].Success),
+			"channel", channel, "result", "success")
+		e.sample("atella_channel_send_total", float64(stats[channel].Failure),
+			"channel", channel, "result", "failure")
+	}
+}
@@ -0,0 +1,111 @@
+package AtellaHttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"../AtellaConfig"
+)
+
+func TestExpositionSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		labels []string
+		want   string
+	}{
+		{"no labels", 1, nil, "m 1\n"},
+		{"labels", 0.5, []string{"host", "a", "sector", "s"},
+			"m{host=\"a\",sector=\"s\"} 0.5\n"},
+		{"escaped label", 1, []string{"host", "a\"b\\c\nd"},
+			"m{host=\"a\\\"b\\\\c\\nd\"} 1\n"},
+		{"odd labels", 2, []string{"host", "a", "dangling"},
+			"m{host=\"a\"} 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &exposition{}
+			e.sample("m", tt.value, tt.labels...)
+			if got := e.buf.String(); got != tt.want {
+				t.Errorf("sample() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpositionLatency(t *testing.T) {
+	e := &exposition{}
+	e.latency("m", AtellaConfig.LatencyStats{})
+	if e.buf.Len() != 0 {
+		t.Errorf("latency() without samples = %q", e.buf.String())
+	}
+	var stats AtellaConfig.LatencyStats
+	stats.Add(1, 10)
+	stats.Add(3, 10)
+	e.latency("m", stats, "host", "a")
+	want := "m{host=\"a\",stat=\"min\"} 1\n" +
+		"m{host=\"a\",stat=\"avg\"} 2\n" +
+		"m{host=\"a\",stat=\"max\"} 3\n"
+	if got := e.buf.String(); got != want {
+		t.Errorf("latency() = %q, want %q", got, want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	c := AtellaConfig.NewConfig()
+	c.Security.Code = "admincode"
+	c.Security.Tokens = []*AtellaConfig.TokenConfig{
+		{Name: "unknown role", Token: "badtok", Role: "root"}}
+	c.Agent.MessagePath = t.TempDir()
+	c.Vector.Set(AtellaConfig.VectorType{
+		Host:     "10.0.0.1",
+		Hostname: "a",
+		Status:   true,
+		Sectors:  []string{"s1", "s2"}})
+	h := New(c)
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		code   int
+	}{
+		{"authorized", http.MethodGet, "admincode", http.StatusOK},
+		{"no token", http.MethodGet, "", http.StatusUnauthorized},
+		{"unknown role", http.MethodGet, "badtok", http.StatusForbidden},
+		{"wrong method", http.MethodPost, "admincode",
+			http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/metrics", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			h.metrics(w, r)
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d", w.Code, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			for _, line := range []string{
+				"# TYPE atella_neighbour_up gauge\n",
+				"atella_neighbour_up{host=\"10.0.0.1\",hostname=\"a\",sector=\"s1\"} 1\n",
+				"atella_neighbour_up{host=\"10.0.0.1\",hostname=\"a\",sector=\"s2\"} 1\n",
+				"atella_spool_messages 0\n",
+			} {
+				if !strings.Contains(body, line) {
+					t.Errorf("metrics don't contain %q", line)
+				}
+			}
+			if strings.Contains(body, "atella_master_verdict") {
+				t.Errorf("metrics of master are exported by non-master")
+			}
+		})
+	}
+}
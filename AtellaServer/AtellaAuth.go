@@ -0,0 +1,195 @@
+package AtellaServer
+
+import (
+	"fmt"
+	"net"
+
+	"../AtellaConfig"
+)
+
+// Processing auth command. Supported forms:
+// auth {code} - plain code phrase, if it allowed by auth mode
+// auth challenge - request nonce for challenge-response
+// auth hmac {hostname} {digest} - answer HMAC(code, nonce||hostname)
+// Return true if connection must be closed
+func (s *AtellaServer) auth(c *ServerClient, msgMap []string) bool {
+	var ok, closeConn bool
+
+	if len(msgMap) < 2 {
+		closeConn = s.authFailed(c, "empty auth")
+		c.Send(fmt.Sprintf("%s auth\n", errMsg))
+		return closeConn
+	}
+
+	switch msgMap[1] {
+	case "challenge":
+		nonce, err := s.newChallenge(c)
+		if err != nil {
+			c.Send(fmt.Sprintf("%s auth\n", errMsg))
+			return false
+		}
+		c.Send(fmt.Sprintf("%s ack challenge %s\n", okMsg, nonce))
+		return false
+
+	case "hmac":
+		if len(msgMap) < 4 {
+			ok, closeConn = s.checkDigest(c, "", "")
+		} else {
+			ok, closeConn = s.checkDigest(c, msgMap[2], msgMap[3])
+		}
+
+	default:
+		ok, closeConn = s.checkCode(c, msgMap[1])
+	}
+
+	if ok {
+		c.Send(fmt.Sprintf("%s ack auth\n", okMsg))
+	} else {
+		c.Send(fmt.Sprintf("%s auth\n", errMsg))
+	}
+	return closeConn
+}
+
+// Function generate and save nonce for challenge-response auth
+func (s *AtellaServer) newChallenge(c *ServerClient) (string, error) {
+	nonce, err := AtellaConfig.RandomHex(16)
+	if err != nil {
+		s.configuration.Logger.LogError(fmt.Sprintf("[Server] Nonce - %s", err))
+		return "", err
+	}
+	c.params.nonce = nonce
+	return nonce, nil
+}
+
+// Function check answer to challenge. Return true if auth success and
+// true if connection must be closed
+func (s *AtellaServer) checkDigest(c *ServerClient, hostname string,
+	digest string) (bool, bool) {
+	// Nonce is valid only for one attempt
+	nonce := c.params.nonce
+	c.params.nonce = ""
+	if nonce == "" || digest == "" {
+		return false, s.authFailed(c, "hmac without challenge")
+	}
+	name, role := s.configuration.GetDigestRole(nonce, hostname, digest)
+	if role == "" {
+		return false, s.authFailed(c, fmt.Sprintf("hmac digest from %s", hostname))
+	}
+	c.params.authHostname = hostname
+	c.params.authName = hostname
+	s.authSuccess(c, "hmac", name, role)
+	return true, false
+}
+
+// Function check plain code phrase or token. Return true if auth success
+// and true if connection must be closed
+func (s *AtellaServer) checkCode(c *ServerClient, code string) (bool, bool) {
+	if !s.configuration.AllowPlainAuth() {
+		return false, s.authFailed(c, "plain code are not allowed")
+	}
+	name, role := s.configuration.GetCodeRole(code)
+	if role == "" {
+		return false, s.authFailed(c, "wrong code")
+	}
+	c.params.authHostname = ""
+	c.params.authName = name
+	s.authSuccess(c, "code", name, role)
+	return true, false
+}
+
+// Function mark client as authenticated with role of used secret
+func (s *AtellaServer) authSuccess(c *ServerClient, method string, name string,
+	role string) {
+	s.configuration.Logger.LogInfo(fmt.Sprintf(
+		"[Server] Client [%d] auth success by %s [%s, role %s]", c.params.id,
+		method, name, role))
+	c.params.canTalk = true
+	c.params.role = role
+}
+
+// Function check, that client may set vector of host. Agent sets only own
+// vector: host must be hostname of hmac auth or name of used token. Admin
+// may set vector of any host
+func (s *AtellaServer) vectorAllowed(c *ServerClient, host string) bool {
+	if c.params.role == AtellaConfig.RoleAdmin || host == c.params.authName {
+		return true
+	}
+	s.configuration.Logger.LogError(fmt.Sprintf(
+		"[Server] Client [%d] from %s authenticated as %s, set vector of %s rejected",
+		c.params.id, c.conn.RemoteAddr(), c.params.authName, host))
+	return false
+}
+
+// Function count failed auth. Return true if connection must be closed
+func (s *AtellaServer) authFailed(c *ServerClient, reason string) bool {
+	c.params.authFailures = c.params.authFailures + 1
+	s.configuration.Logger.LogError(fmt.Sprintf(
+		"[Server] Client [%d] from %s failed auth [%d]: %s", c.params.id,
+		c.conn.RemoteAddr(), c.params.authFailures, reason))
+
+	max := s.configuration.Security.MaxAuthFailures
+	if max > 0 && c.params.authFailures >= max {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Client [%d] from %s reached %d failed auth. Force closing connection.",
+			c.params.id, c.conn.RemoteAddr(), c.params.authFailures))
+		return true
+	}
+	return false
+}
+
+// Function return role, required for command and its subcommand. Empty
+// role means, that command may be used without auth
+func commandRole(cmd string, sub string) string {
+	switch cmd {
+	case "ping", "help", "hello", "auth", "quit", "exit":
+		return ""
+	case "export", "get":
+		return AtellaConfig.RoleRead
+	case "set":
+		// Master vector are cluster-wide, only masters replicate it
+		if sub == "master" {
+			return AtellaConfig.RoleAdmin
+		}
+		return AtellaConfig.RoleAgent
+	}
+	return AtellaConfig.RoleAdmin
+}
+
+// Function check access of client to command. Return reason of denial
+// ("unauthorized" or "forbidden") or empty string if access granted
+func (s *AtellaServer) checkAccess(c *ServerClient, cmd string,
+	sub string) string {
+	role := commandRole(cmd, sub)
+	if role == "" {
+		return ""
+	}
+	if !c.params.canTalk {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Unauthorized cmd %s from %s [%d]", cmd,
+			c.conn.RemoteAddr(), c.params.id))
+		return "unauthorized"
+	}
+	if !AtellaConfig.RoleAllows(c.params.role, role) {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Forbidden cmd %s for role %s from %s [%d]", cmd,
+			c.params.role, c.conn.RemoteAddr(), c.params.id))
+		return "forbidden"
+	}
+	return ""
+}
+
+// Function return name of master_servers entry of client. Client is
+// master, if it passed hmac auth with hostname of master or connected from
+// address of master. Return empty string if client is not listed master
+func (s *AtellaServer) clientMaster(c *ServerClient) string {
+	if c.params.authHostname != "" {
+		if name := s.configuration.MasterName(c.params.authHostname); name != "" {
+			return name
+		}
+	}
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return s.configuration.MasterName(host)
+}
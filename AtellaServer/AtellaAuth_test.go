@@ -0,0 +1,151 @@
+package AtellaServer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"../AtellaConfig"
+)
+
+// Connection, which saves replies of server
+type testConn struct {
+	net.Conn
+	out  bytes.Buffer
+	addr net.Addr
+}
+
+func (c *testConn) Write(b []byte) (int, error) { return c.out.Write(b) }
+func (c *testConn) RemoteAddr() net.Addr        { return c.addr }
+func (c *testConn) Close() error                { return nil }
+
+func newTestServer() *AtellaServer {
+	conf := AtellaConfig.NewConfig()
+	conf.Agent.Hostname = "me"
+	conf.Security.Code = "admincode"
+	conf.Security.Tokens = []*AtellaConfig.TokenConfig{
+		{Name: "dashboard", Token: "readtok", Role: AtellaConfig.RoleRead},
+		{Name: "agent1", Token: "agenttok", Role: AtellaConfig.RoleAgent}}
+	return New(conf, "127.0.0.1:5223")
+}
+
+func newTestClient(s *AtellaServer, address string) *ServerClient {
+	c := &ServerClient{
+		conn:   &testConn{addr: &net.TCPAddr{IP: net.ParseIP(address), Port: 40000}},
+		Server: s}
+	s.OnNewClient(c)
+	return c
+}
+
+// Function send message to server and return reply
+func send(c *ServerClient, msg string) string {
+	conn := c.conn.(*testConn)
+	conn.out.Reset()
+	c.Server.OnNewMessage(c, msg+"\n")
+	return strings.TrimRight(conn.out.String(), "\n")
+}
+
+func TestCommandRoles(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		msg  string
+		want string
+	}{
+		{"read gets", "readtok", "get hostname", "+OK ack hostname me"},
+		{"read exports", "readtok", "export master", "+OK ack master {}"},
+		{"read can't set host", "readtok", "set host a", "-ERR forbidden"},
+		{"read can't set vector", "readtok", "set vector dashboard []",
+			"-ERR forbidden"},
+		{"agent sets host", "agenttok", "set host a", "+OK ack host a"},
+		{"agent can't set master", "agenttok", "set master {}", "-ERR forbidden"},
+		{"admin sets master", "admincode", "set master {}", "-ERR set master"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			c := newTestClient(s, "203.0.113.1")
+			if got := send(c, "auth "+tt.code); got != "+OK ack auth" {
+				t.Fatalf("auth = %q", got)
+			}
+			if got := send(c, tt.msg); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthGating(t *testing.T) {
+	s := newTestServer()
+	c := newTestClient(s, "203.0.113.1")
+	steps := []struct {
+		msg  string
+		want string
+	}{
+		{"ping", "pong"},
+		{"get hostname", "-ERR unauthorized"},
+		{"export vector", "-ERR unauthorized"},
+		{"set vector me []", "-ERR unauthorized"},
+		{"auth wrongcode", "-ERR auth"},
+		{"get hostname", "-ERR unauthorized"},
+		{"auth admincode", "+OK ack auth"},
+		{"get hostname", "+OK ack hostname me"},
+	}
+	for _, st := range steps {
+		if got := send(c, st.msg); got != st.want {
+			t.Errorf("%s = %q, want %q", st.msg, got, st.want)
+		}
+	}
+}
+
+func TestAuthFailuresClose(t *testing.T) {
+	s := newTestServer()
+	s.configuration.Security.MaxAuthFailures = 2
+	c := newTestClient(s, "203.0.113.1")
+	if s.OnNewMessage(c, "auth wrongcode\n") {
+		t.Fatal("connection closed after first failure")
+	}
+	if !s.OnNewMessage(c, "auth wrongcode\n") {
+		t.Error("connection isn't closed after max failures")
+	}
+}
+
+func TestSetVectorIdentity(t *testing.T) {
+	hmac := func(c *ServerClient, token string, hostname string) string {
+		nonce := strings.TrimPrefix(send(c, "auth challenge"), "+OK ack challenge ")
+		return send(c, fmt.Sprintf("auth hmac %s %s", hostname,
+			AtellaConfig.GetAuthDigest(token, nonce, hostname)))
+	}
+	code := func(c *ServerClient, token string, hostname string) string {
+		return send(c, "auth "+token)
+	}
+	tests := []struct {
+		name  string
+		auth  func(*ServerClient, string, string) string
+		token string
+		host  string
+		want  string
+	}{
+		{"hmac own host", hmac, "agenttok", "h1", "+OK ack set"},
+		{"hmac other host", hmac, "agenttok", "h2", "-ERR set vector"},
+		{"token own name", code, "agenttok", "agent1", "+OK ack set"},
+		{"token other host", code, "agenttok", "h1", "-ERR set vector"},
+		{"admin any host", code, "admincode", "h2", "+OK ack set"},
+		{"admin hmac any host", hmac, "admincode", "h2", "+OK ack set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			c := newTestClient(s, "203.0.113.1")
+			if got := tt.auth(c, tt.token, "h1"); got != "+OK ack auth" {
+				t.Fatalf("auth = %q", got)
+			}
+			msg := fmt.Sprintf("set vector %s []", tt.host)
+			if got := send(c, msg); got != tt.want {
+				t.Errorf("%s = %q, want %q", msg, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package AtellaServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"../AtellaConfig"
+)
+
+// Function process hello of client and return hello of server
+func (s *AtellaServer) hello(c *ServerClient, peer *AtellaConfig.Hello) *AtellaConfig.Hello {
+	local := s.configuration.GetHello(s.tlsConfig != nil)
+	c.params.peer = peer
+	c.params.capabilities = AtellaConfig.CommonCapabilities(local.Capabilities,
+		peer.Capabilities)
+
+	missing := AtellaConfig.MissingCapabilities(local.Capabilities, peer.Capabilities)
+	if peer.ProtocolVersion != local.ProtocolVersion || len(missing) > 0 {
+		s.configuration.Logger.LogWarning(fmt.Sprintf(
+			"[Server] Client [%d] %s version %s, protocol %d, missing capabilities [%s]",
+			c.params.id, peer.Hostname, peer.Version, peer.ProtocolVersion,
+			strings.Join(missing, ", ")))
+	}
+	s.configuration.Logger.LogInfo(fmt.Sprintf(
+		"[Server] Client [%d] hello, common capabilities [%s]", c.params.id,
+		strings.Join(c.params.capabilities, ", ")))
+	return local
+}
+
+// Processing text hello: hello {json}. Protocol of next messages will be
+// detected again, because client may switch to JSON frames
+func (s *AtellaServer) helloText(c *ServerClient, msg string) {
+	var peer AtellaConfig.Hello
+
+	msgMap := strings.SplitN(msg, " ", 2)
+	if len(msgMap) < 2 {
+		c.Send(fmt.Sprintf("%s hello\n", errMsg))
+		return
+	}
+	err := json.Unmarshal([]byte(msgMap[1]), &peer)
+	if err != nil {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Client [%d] hello - %s", c.params.id, err))
+		c.Send(fmt.Sprintf("%s hello\n", errMsg))
+		return
+	}
+	local, _ := json.Marshal(s.hello(c, &peer))
+	c.Send(fmt.Sprintf("%s ack hello %s\n", okMsg, local))
+	c.params.protocol = ""
+}
@@ -0,0 +1,171 @@
+package AtellaServer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"../AtellaConfig"
+)
+
+// Function send JSON frame via connection
+func (c *ServerClient) SendFrame(frame interface{}) error {
+	b, err := AtellaConfig.EncodeFrame(frame)
+	if err != nil {
+		return err
+	}
+	return c.SendBytes(b)
+}
+
+// Processing each JSON frame, receiving from clients.
+// Return true if connection must be closed
+func (s *AtellaServer) OnNewFrame(c *ServerClient, msg string) bool {
+	var req AtellaConfig.Request
+
+	if msg == "" {
+		return false
+	}
+	err := json.Unmarshal([]byte(msg), &req)
+	if err != nil {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Client [%d] bad frame - %s", c.params.id, err))
+		c.SendFrame(AtellaConfig.NewErrorResponse("", fmt.Sprintf("bad frame: %s", err)))
+		return false
+	}
+	s.configuration.Logger.LogInfo(fmt.Sprintf("[Server] Server receive frame [%s]",
+		req.Cmd))
+
+	if !AtellaConfig.IsSupportedVersion(req.Version) {
+		s.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Client [%d] unsupported frame version %d", c.params.id,
+			req.Version))
+		c.SendFrame(AtellaConfig.NewErrorResponse(req.Cmd,
+			fmt.Sprintf("unsupported version %d", req.Version)))
+		return false
+	}
+
+	if denial := s.checkAccess(c, AtellaConfig.CommandVerb(req.Cmd),
+		AtellaConfig.CommandSubcommand(req.Cmd)); denial != "" {
+		c.SendFrame(AtellaConfig.NewErrorResponse(req.Cmd, denial))
+		return false
+	}
+
+	res := AtellaConfig.NewResponse(req.Cmd)
+	closeConn := false
+
+	switch req.Cmd {
+	case "quit", "exit":
+		closeConn = true
+
+	case "ping":
+		res.Value = "pong"
+
+	case "help":
+		res.Commands = []string{
+			"ping", "help", "quit", "hello {hello}",
+			"auth {code}", "auth_challenge", "auth_hmac {hostname, digest}",
+			"export_vector", "export_master", "export_verdict",
+			"get_whoami", "get_hostname", "get_version", "get_info", "get_leader",
+			"set_host {host}", "set_vector {host, vector}", "set_master {master}"}
+
+	case "hello":
+		if req.Hello == nil {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "hello are not specifyed")
+			break
+		}
+		res.Hello = s.hello(c, req.Hello)
+
+	case "auth":
+		var ok bool
+		ok, closeConn = s.checkCode(c, req.Code)
+		if !ok {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "auth")
+		}
+
+	case "auth_challenge":
+		nonce, err := s.newChallenge(c)
+		if err != nil {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "auth")
+		} else {
+			res.Nonce = nonce
+		}
+
+	case "auth_hmac":
+		var ok bool
+		ok, closeConn = s.checkDigest(c, req.Hostname, req.Digest)
+		if !ok {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "auth")
+		}
+
+	case "export_vector":
+		res.Vector = s.configuration.Vector.Snapshot()
+
+	case "export_master":
+		json.Unmarshal(s.configuration.GetJsonMasterVector(), &res.Master)
+		res.Verdicts = s.configuration.GetMasterVerdicts()
+
+	case "export_verdict":
+		res.Verdicts = s.configuration.GetMasterVerdicts()
+
+	case "get_whoami":
+		res.Value = fmt.Sprintf("%d", c.params.id)
+
+	case "get_hostname":
+		res.Value = s.configuration.Agent.Hostname
+
+	case "get_version":
+		res.Value = AtellaConfig.Version
+
+	case "get_info":
+		res.Info = s.getInfo(c)
+
+	case "get_leader":
+		if !s.configuration.Agent.Master {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "not a master")
+			break
+		}
+		res.Value = s.configuration.GetLeader()
+
+	case "set_host":
+		if req.Host == "" {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "host are not specifyed")
+			break
+		}
+		c.params.currentClientHostname = req.Host
+		res.Value = req.Host
+
+	case "set_vector":
+		if req.Host == "" {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "host are not specifyed")
+			break
+		}
+		if !s.vectorAllowed(c, req.Host) {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "host is not authenticated client")
+			break
+		}
+		c.params.currentClientHostname = req.Host
+		s.SetVector(req.Host, req.Vector)
+
+	case "set_master":
+		if !s.configuration.Agent.Master {
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "not a master")
+			break
+		}
+		from := s.clientMaster(c)
+		if from == "" {
+			s.configuration.Logger.LogError(fmt.Sprintf(
+				"[Server] Client [%d] from %s is not a master, set master rejected",
+				c.params.id, c.conn.RemoteAddr()))
+			res = AtellaConfig.NewErrorResponse(req.Cmd, "client is not a master")
+			break
+		}
+		s.MergeMaster(from, req.Master)
+
+	default:
+		s.configuration.Logger.LogWarning(fmt.Sprintf("[Server] Unknown cmd %s [%s]\n",
+			req.Cmd, msg))
+		res = AtellaConfig.NewErrorResponse(req.Cmd, "unknown command")
+	}
+
+	c.SendFrame(res)
+	return closeConn
+}
@@ -0,0 +1,120 @@
+package AtellaServer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"../AtellaConfig"
+)
+
+// Function send frame to server and return decoded response
+func sendFrame(t *testing.T, c *ServerClient, msg string) *AtellaConfig.Response {
+	var res AtellaConfig.Response
+	reply := send(c, msg)
+	if strings.Contains(reply, "\n") {
+		t.Fatalf("reply of %s isn't one line: %q", msg, reply)
+	}
+	if err := json.Unmarshal([]byte(reply), &res); err != nil {
+		t.Fatalf("reply of %s - %s", msg, err)
+	}
+	return &res
+}
+
+func TestJsonFraming(t *testing.T) {
+	s := newTestServer()
+	c := newTestClient(s, "203.0.113.1")
+	steps := []struct {
+		msg   string
+		err   string
+		value string
+	}{
+		{`{"version":2,"cmd":"ping"}`, "", "pong"},
+		{`{"version":1,"cmd":"ping"}`, "unsupported version 1", ""},
+		{`{"cmd":"ping"}`, "unsupported version 0", ""},
+		{`{"version":2,"cmd":"get_hostname"}`, "unauthorized", ""},
+		{`{"version":2,"cmd":"auth","code":"readtok"}`, "", ""},
+		{`{"version":2,"cmd":"get_hostname"}`, "", "me"},
+		{`{"version":2,"cmd":"set_host","host":"a"}`, "forbidden", ""},
+		{`{"version":2,"cmd":"get_nothing"}`, "unknown command", ""},
+		// Protocol are fixed by first message, so text is a bad frame
+		{"ping", "bad frame: invalid character 'p' looking for beginning of value", ""},
+	}
+	for _, st := range steps {
+		res := sendFrame(t, c, st.msg)
+		if res.Version != AtellaConfig.ProtocolVersion {
+			t.Errorf("%s version = %d", st.msg, res.Version)
+		}
+		wantStatus := AtellaConfig.StatusOk
+		if st.err != "" {
+			wantStatus = AtellaConfig.StatusErr
+		}
+		if res.Status != wantStatus || res.Error != st.err || res.Value != st.value {
+			t.Errorf("%s = %s %q %q, want %s %q %q", st.msg, res.Status, res.Error,
+				res.Value, wantStatus, st.err, st.value)
+		}
+	}
+}
+
+func TestSetMaster(t *testing.T) {
+	frame := `{"version":2,"cmd":"set_master","master":{"r1":{"timestamp":1,"vector":[]}}}`
+	tests := []struct {
+		name    string
+		master  bool
+		address string
+		code    string
+		err     string
+	}{
+		{"master accepts master", true, "198.51.100.2", "admincode", ""},
+		{"master rejects other host", true, "203.0.113.1", "admincode",
+			"client is not a master"},
+		{"agent rejects master", false, "198.51.100.2", "admincode", "not a master"},
+		{"agent role is forbidden", true, "198.51.100.2", "agenttok", "forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			s.configuration.Agent.Master = tt.master
+			s.configuration.MasterServers.Hosts = []string{"198.51.100.1 me",
+				"198.51.100.2 m2"}
+			c := newTestClient(s, tt.address)
+			sendFrame(t, c, `{"version":2,"cmd":"auth","code":"`+tt.code+`"}`)
+			res := sendFrame(t, c, frame)
+			if res.Error != tt.err {
+				t.Errorf("set_master error = %q, want %q", res.Error, tt.err)
+			}
+			_, merged := s.configuration.GetMasterEntries()["r1"]
+			if merged != (tt.err == "") {
+				t.Errorf("vector of r1 merged = %v", merged)
+			}
+		})
+	}
+}
+
+func TestGetLeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		master bool
+		err    string
+		value  string
+	}{
+		{"any client reads leader", true, "", "me"},
+		{"agent isn't master", false, "not a master", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer()
+			s.configuration.Agent.Master = tt.master
+			s.configuration.MasterServers.Hosts = []string{"198.51.100.1 me",
+				"198.51.100.2 m2"}
+			// Client is neither master nor admin
+			c := newTestClient(s, "203.0.113.1")
+			sendFrame(t, c, `{"version":2,"cmd":"auth","code":"readtok"}`)
+			res := sendFrame(t, c, `{"version":2,"cmd":"get_leader"}`)
+			if res.Error != tt.err || res.Value != tt.value {
+				t.Errorf("get_leader = %q %q, want %q %q", res.Error, res.Value,
+					tt.err, tt.value)
+			}
+		})
+	}
+}
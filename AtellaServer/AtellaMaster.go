@@ -1,31 +1,213 @@
 package AtellaServer
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"../AtellaConfig"
 )
 
-// Function impement master server logic
+// Function impement master server logic. Local vector are saved every
+// interval by scheduled job
 func (s *AtellaServer) MasterServer() {
 	if s.configuration.Agent.Master {
 		s.configuration.Logger.LogSystem("[Server] I'm master server")
 	} else {
 		s.configuration.Logger.LogSystem("[Server] I'm not a master server")
-		s.CloseReplyMaster = true
 		return
 	}
 
-  var interrupt bool = false
-	go func() {
-		<-s.stopRequest
+	s.configuration.ResetMasterVector()
+	s.configuration.StartElection()
+	leader := ""
+
+	s.scheduler.Go(func(ctx context.Context) {
+		<-ctx.Done()
 		s.configuration.Logger.LogSystem("[Server] Stopping master server")
-		interrupt = true
-	}()
+	})
+	// Master checks own neighbours too, save local vector as reported by me
+	s.scheduler.Every(AtellaConfig.Seconds(s.configuration.Agent.Interval),
+		func(ctx context.Context) {
+			s.SetVector(s.configuration.Agent.Hostname,
+				s.configuration.Vector.Snapshot())
+			if cur := s.configuration.GetLeader(); cur != leader {
+				leader = cur
+				s.configuration.Logger.LogSystem(fmt.Sprintf(
+					"[Server] Leader of masters is %s", leader))
+			}
+		})
+}
+
+// Function save vector, received from reporter, into master vector and
+// process changes
+func (s *AtellaServer) SetVector(reporter string, vec []AtellaConfig.VectorType) {
+	prev, exist := s.configuration.SetMasterVector(reporter, vec)
+	if !s.configuration.Agent.Master {
+		return
+	}
+	s.processVector(reporter, prev, exist, vec)
+}
+
+// Function merge master vector entries, replicated by other master, and
+// process changes of replaced entries. From is name of authenticated
+// master in master_servers list
+func (s *AtellaServer) MergeMaster(from string,
+	entries map[string]AtellaConfig.MasterEntry) {
+	s.configuration.MasterSeen(from)
+	prev := s.configuration.MergeMasterEntries(entries)
+	updated := make([]string, 0)
+	for reporter, entry := range prev {
+		updated = append(updated, reporter)
+		s.processVector(reporter, entry.Vector, entry.Timestamp > 0,
+			entries[reporter].Vector)
+	}
+	if len(updated) > 0 {
+		s.configuration.Logger.LogInfo(fmt.Sprintf(
+			"[Server] Master %s replicated vectors of [%s]", from,
+			strings.Join(updated, ", ")))
+	}
+}
+
+// Function report about status transitions of hosts in new vector of
+// reporter. Transitions of flapping hosts are not reported, only start and
+// end of flapping. Only leader of masters reports, standbys keep state
+func (s *AtellaServer) processVector(reporter string,
+	prev []AtellaConfig.VectorType, exist bool, vec []AtellaConfig.VectorType) {
+	leader := s.configuration.IsLeader()
+	if exist && leader {
+		s.reportTransitions(reporter, prev, vec)
+	}
+	s.updateProblems(reporter, vec, leader)
+}
+
+// Function report about status transitions between previous and new
+// vector of reporter
+func (s *AtellaServer) reportTransitions(reporter string,
+	prev []AtellaConfig.VectorType, vec []AtellaConfig.VectorType) {
+	for _, cur := range vec {
+		old := getVectorElByHost(prev, cur.Host)
+		// Host was never probed by reporter, it is not a transition
+		if old == nil || !old.IsProbed() {
+			continue
+		}
+		if old.Flapping != cur.Flapping {
+			s.reportFlapping(reporter, cur)
+			continue
+		}
+		if old.Status == cur.Status || cur.Flapping {
+			continue
+		}
+		s.reportTransition(reporter, cur)
+	}
+}
+
+// Function merge host problems, seen by all reporters, and report about new
+// and resolved ones. Same problem from several reporters is reported once.
+// Report means, that local master is leader
+func (s *AtellaServer) updateProblems(reporter string,
+	vec []AtellaConfig.VectorType, report bool) {
+	s.problemsMux.Lock()
+	defer s.problemsMux.Unlock()
+	for _, cur := range vec {
+		// Without info we know nothing about host problems
+		if cur.Info == nil {
+			continue
+		}
+		current := s.getHostProblems(cur.Host)
+		active, exist := s.problems[cur.Host]
+		if !exist {
+			active = make(map[string]string)
+		}
+		for key, message := range current {
+			if _, exist := active[key]; !exist && report {
+				s.reportProblem(reporter, cur, fmt.Sprintf("Problem: %s", message))
+			}
+		}
+		for key, message := range active {
+			if _, exist := current[key]; !exist && report {
+				s.reportProblem(reporter, cur, fmt.Sprintf("Resolved: %s", message))
+			}
+		}
+		if len(current) == 0 {
+			delete(s.problems, cur.Host)
+		} else {
+			s.problems[cur.Host] = current
+		}
+	}
+}
+
+// Function return problems of host, reported by any reporter
+func (s *AtellaServer) getHostProblems(host string) map[string]string {
+	res := make(map[string]string)
+	s.configuration.MasterVectorMutex.RLock()
+	defer s.configuration.MasterVectorMutex.RUnlock()
+	for _, vector := range s.configuration.MasterVector {
+		el := getVectorElByHost(vector, host)
+		if el == nil {
+			continue
+		}
+		for _, p := range el.Problems {
+			res[p.Key] = p.Message
+		}
+	}
+	return res
+}
+
+// Function create report about host problem
+func (s *AtellaServer) reportProblem(reporter string, vec AtellaConfig.VectorType,
+	message string) {
+	msg := fmt.Sprintf("%s [%s] in sector [%s]. Reported by %s", message, vec.Host,
+		strings.Join(vec.Sectors, ", "), reporter)
+	s.configuration.Logger.LogSystem(fmt.Sprintf("[Server] %s", msg))
+	s.configuration.Report(msg, "all")
+}
+
+// Function create report about host status transition
+func (s *AtellaServer) reportTransition(reporter string, vec AtellaConfig.VectorType) {
+	state := "down"
+	if vec.Status {
+		state = "up"
+	} else if vec.Reason != "" {
+		state = fmt.Sprintf("down (%s at %s)", vec.Reason, vec.Stage)
+	}
+	verdict := s.configuration.GetMasterVerdict(vec.Host)
+	msg := fmt.Sprintf("Host %s [%s] in sector [%s] is %s. Reported by %s. "+
+		"Verdict: %s (%d of %d reporters see it down)",
+		vec.Host, vec.Hostname, strings.Join(vec.Sectors, ", "), state, reporter,
+		verdict.Verdict, len(verdict.Down), len(verdict.Reporters))
+	s.configuration.Logger.LogSystem(fmt.Sprintf("[Server] %s", msg))
+	s.configuration.Report(msg, "all")
+}
+
+// Function create report about start or end of host flapping
+func (s *AtellaServer) reportFlapping(reporter string, vec AtellaConfig.VectorType) {
+	state := "down"
+	if vec.Status {
+		state = "up"
+	}
+	var msg string
+	if vec.Flapping {
+		msg = fmt.Sprintf("Host %s [%s] in sector [%s] is flapping: %d status changes "+
+			"in %d seconds. Reported by %s",
+			vec.Host, vec.Hostname, strings.Join(vec.Sectors, ", "),
+			vec.RecentTransitions, s.configuration.Agent.FlapWindow, reporter)
+	} else {
+		msg = fmt.Sprintf("Host %s [%s] in sector [%s] stopped flapping and is %s. "+
+			"Reported by %s",
+			vec.Host, vec.Hostname, strings.Join(vec.Sectors, ", "), state, reporter)
+	}
+	s.configuration.Logger.LogSystem(fmt.Sprintf("[Server] %s", msg))
+	s.configuration.Report(msg, "all")
+}
 
-	s.configuration.MasterVectorMutex.Lock()
-	s.configuration.MasterVector = make(map[string][]AtellaConfig.VectorType, 0)
-	s.configuration.MasterVectorMutex.Unlock()
-	for !interrupt {
-		AtellaConfig.Pause(s.configuration.Agent.Interval, &interrupt)
+// Function return vector element in vector array if element exist.
+// Else return nil
+func getVectorElByHost(vector []AtellaConfig.VectorType, host string) *AtellaConfig.VectorType {
+	for i := 0; i < len(vector); i = i + 1 {
+		if vector[i].Host == host {
+			return &vector[i]
+		}
 	}
-	s.CloseReplyMaster = true
+	return nil
 }
@@ -2,12 +2,14 @@ package AtellaServer
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"math"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"../AtellaConfig"
@@ -25,6 +27,14 @@ type clientParams struct {
 	emptyMessageCnt         uint64
 	currentClientHostname   string
 	currentClientVectorJson string
+	nonce                   string
+	authHostname            string
+	authName                string
+	authFailures            int64
+	role                    string
+	protocol                string
+	peer                    *AtellaConfig.Hello
+	capabilities            []string
 }
 
 // Client description
@@ -36,27 +46,36 @@ type ServerClient struct {
 
 // Server parameters
 type AtellaServer struct {
-	address          string
-	configuration    *AtellaConfig.Config
-	global           uint64
-	tlsConfig        *tls.Config
-	reloadRequest    chan struct{}
-	stopRequest      chan struct{}
-	CloseReplyServer bool
-	CloseReplyMaster bool
+	address       string
+	configuration *AtellaConfig.Config
+	global        uint64
+	tlsConfig     *tls.Config
+	reloadRequest chan struct{}
+	scheduler     *AtellaConfig.Scheduler
+	problemsMux   sync.Mutex
+	problems      map[string]map[string]string
 }
 
-// Processing client
-func (c *ServerClient) listen() {
-	c.Server.OnNewClient(c)
+// Processing client. Connection are closed when server stopped
+func (c *ServerClient) listen(ctx context.Context) {
 	reader := bufio.NewReader(c.conn)
 	var exit = false
-	
+
+	if err := c.handshake(); err != nil {
+		c.Server.configuration.Logger.LogError(fmt.Sprintf(
+			"[Server] Client [%d] TLS handshake - %s", c.params.id, err))
+		c.conn.Close()
+		c.Server.OnClientConnectionClosed(c, err)
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-c.Server.stopRequest
-		exit = true
-		if c.conn != nil {
+		select {
+		case <-ctx.Done():
 			c.conn.Close()
+		case <-done:
 		}
 	}()
 
@@ -74,6 +93,19 @@ func (c *ServerClient) listen() {
 	return
 }
 
+// Function make TLS handshake with deadline, so idle connection doesn.t
+// hold routine. Plain connections are skipped
+func (c *ServerClient) handshake() error {
+	conn, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	conn.SetDeadline(time.Now().Add(
+		time.Duration(c.Server.configuration.Agent.NetTimeout) * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	return conn.Handshake()
+}
+
 // Function send string via connection
 func (c *ServerClient) Send(message string) error {
 	_, err := c.conn.Write([]byte(message))
@@ -133,29 +165,68 @@ func (s *AtellaServer) OnNewMessage(c *ServerClient, message string) bool {
 		c.params.emptyMessageCnt = 0
 	}
 
+	// Protocol are detected by first non-empty message
+	if msg != "" && c.params.protocol == "" {
+		c.params.protocol = AtellaConfig.ProtocolText
+		if AtellaConfig.IsJsonFrame(msg) {
+			c.params.protocol = AtellaConfig.ProtocolJson
+		}
+		s.configuration.Logger.LogInfo(fmt.Sprintf("[Server] Client [%d] speaks %s protocol",
+			c.params.id, c.params.protocol))
+	}
+	if c.params.protocol == AtellaConfig.ProtocolJson {
+		return s.OnNewFrame(c, msg)
+	}
+
 	s.configuration.Logger.LogInfo(fmt.Sprintf("[Server] Server receive [%s | %d]", msg, len(msg)))
+	if msg != "" {
+		sub := ""
+		if len(msgMap) > 1 {
+			sub = msgMap[1]
+		}
+		if denial := s.checkAccess(c, msgMap[0], sub); denial != "" {
+			c.Send(fmt.Sprintf("%s %s\n", errMsg, denial))
+			return false
+		}
+	}
+
 	switch msgMap[0] {
 	// Commands, dont.t require security check
 	case "quit", "exit":
 		c.Send(fmt.Sprintf("%s bye!\n", okMsg))
 		return true
 
+	case "ping":
+		c.Send("pong")
+
+	case "help":
+		c.help()
+
+	case "hello":
+		s.helloText(c, msg)
+
+	// Commands, require security check
 	case "export":
 		if len(msgMap) > 1 {
 			if msgMap[1] == "vector" {
 				c.Send(fmt.Sprintf("%s ack vector %s\n", okMsg, s.configuration.GetJsonVector()))
 				s.configuration.PrintJsonVector()
 			} else if msgMap[1] == "master" {
-				c.Send(fmt.Sprintf("%s ack master %s\n", okMsg, s.configuration.GetJsonMasterVector()))
-				s.configuration.PrintJsonMasterVector()
+				if len(msgMap) > 2 && msgMap[2] == "verdict" {
+					c.Send(fmt.Sprintf("%s ack verdict %s\n", okMsg,
+						s.configuration.GetJsonMasterVerdicts()))
+				} else {
+					c.Send(fmt.Sprintf("%s ack master %s\n", okMsg, s.configuration.GetJsonMasterVector()))
+					s.configuration.PrintJsonMasterVector()
+				}
 			}
 		}
 
-	case "ping":
-		c.Send("pong")
-
-	// Commands, require security check
 	case "get":
+		if len(msgMap) < 2 {
+			c.Send(fmt.Sprintf("%s get\n", errMsg))
+			break
+		}
 		switch msgMap[1] {
 		case "whoami":
 			c.Send(fmt.Sprintf("%s ack whoami %d\n", okMsg, c.params.id))
@@ -163,12 +234,26 @@ func (s *AtellaServer) OnNewMessage(c *ServerClient, message string) bool {
 			c.Send(fmt.Sprintf("%s ack hostname %s\n", okMsg, s.configuration.Agent.Hostname))
 		case "version":
 			c.Send(fmt.Sprintf("%s ack version %s\n", okMsg, AtellaConfig.Version))
+		case "info":
+			js, _ := json.Marshal(s.getInfo(c))
+			c.Send(fmt.Sprintf("%s ack info %s\n", okMsg, js))
+		case "leader":
+			if !s.configuration.Agent.Master {
+				c.Send(fmt.Sprintf("%s get leader\n", errMsg))
+				break
+			}
+			c.Send(fmt.Sprintf("%s ack leader %s\n", okMsg,
+				s.configuration.GetLeader()))
 		default:
 			s.configuration.Logger.LogWarning(fmt.Sprintf("[Server] Unknown cmd %s [%s]\n",
 				msgMap[1], msg))
 		}
 
 	case "set":
+		if len(msgMap) < 2 {
+			c.Send(fmt.Sprintf("%s set\n", errMsg))
+			break
+		}
 		switch msgMap[1] {
 		case "host":
 			if len(msgMap) > 2 {
@@ -179,37 +264,60 @@ func (s *AtellaServer) OnNewMessage(c *ServerClient, message string) bool {
 			}
 		case "vector":
 			if len(msgMap) > 3 {
-				c.params.currentClientHostname = msgMap[2]
-				c.params.currentClientVectorJson = msgMap[3]
+				// Vector json may contain spaces, so it is the rest of message
+				vecMap := strings.SplitN(msg, " ", 4)
 				var vec []AtellaConfig.VectorType
-				json.Unmarshal([]byte(c.params.currentClientVectorJson), &vec)
-				s.configuration.MasterVectorMutex.Lock()
-				s.configuration.MasterVector[c.params.currentClientHostname] = vec
-				s.configuration.MasterVectorMutex.Unlock()
+				err := json.Unmarshal([]byte(vecMap[3]), &vec)
+				if err != nil {
+					s.configuration.Logger.LogError(fmt.Sprintf(
+						"[Server] Client [%d] set vector - %s", c.params.id, err))
+					c.Send(fmt.Sprintf("%s set vector\n", errMsg))
+					break
+				}
+				if !s.vectorAllowed(c, vecMap[2]) {
+					c.Send(fmt.Sprintf("%s set vector\n", errMsg))
+					break
+				}
+				c.params.currentClientHostname = vecMap[2]
+				c.params.currentClientVectorJson = vecMap[3]
+				s.SetVector(c.params.currentClientHostname, vec)
 				c.Send(fmt.Sprintf("%s ack set\n", okMsg))
 			} else {
 				c.Send(fmt.Sprintf("%s set vector\n", errMsg))
 			}
+		case "master":
+			if len(msgMap) < 3 || !s.configuration.Agent.Master {
+				c.Send(fmt.Sprintf("%s set master\n", errMsg))
+				break
+			}
+			from := s.clientMaster(c)
+			if from == "" {
+				s.configuration.Logger.LogError(fmt.Sprintf(
+					"[Server] Client [%d] from %s is not a master, set master rejected",
+					c.params.id, c.conn.RemoteAddr()))
+				c.Send(fmt.Sprintf("%s set master\n", errMsg))
+				break
+			}
+			// Entries json may contain spaces, so it is the rest of message
+			var entries map[string]AtellaConfig.MasterEntry
+			err := json.Unmarshal([]byte(strings.SplitN(msg, " ", 3)[2]), &entries)
+			if err != nil {
+				s.configuration.Logger.LogError(fmt.Sprintf(
+					"[Server] Client [%d] set master - %s", c.params.id, err))
+				c.Send(fmt.Sprintf("%s set master\n", errMsg))
+				break
+			}
+			s.MergeMaster(from, entries)
+			c.Send(fmt.Sprintf("%s ack set\n", okMsg))
 		default:
 			s.configuration.Logger.LogWarning(fmt.Sprintf("[Server] Unknown cmd %s [%s]\n",
 				msgMap[1], msg))
 		}
 
-	case "help":
-		c.help()
-
 	// Auth command
 	case "auth":
-		if len(msgMap) > 1 && msgMap[1] == s.configuration.Security.Code {
-			s.configuration.Logger.LogInfo(fmt.Sprintf("[Server] Code accept, auth success"))
-			c.Send(fmt.Sprintf("%s ack auth\n", okMsg))
-			c.params.canTalk = true
-		} else {
-			s.configuration.Logger.LogInfo(fmt.Sprintf("[Server] Server receive [%s], failed auth",
-			msgMap[1]))
-			c.Send(fmt.Sprintf("%s auth\n", errMsg))
+		return s.auth(c, msgMap)
 
-		}
 	default:
 		s.configuration.Logger.LogWarning(fmt.Sprintf("[Server] Unknown cmd %s [%s]\n",
 			msgMap[0], msg))
@@ -220,58 +328,83 @@ func (s *AtellaServer) OnNewMessage(c *ServerClient, message string) bool {
 
 func (c *ServerClient) help() {
 	c.Send("ping\n")
+	c.Send("hello {json}\n")
 	c.Send("auth {code}\n")
+	c.Send("auth challenge\n")
+	c.Send("auth hmac {hostname} {digest}\n")
 	c.Send("export {vector/master}\n")
+	c.Send("export master verdict\n")
 	c.Send("get whoami\n")
 	c.Send("get hostname\n")
 	c.Send("get version\n")
+	c.Send("get info\n")
+	c.Send("get leader\n")
 	c.Send("set host {hostname}\n")
 	c.Send("set vector {hostname} {vector}\n")
+	c.Send("set master {entries}\n")
 	c.Send("exit\n")
 	c.Send(fmt.Sprintf("%s\n", okMsg))
 }
 
-// Listen for connections
+// Function collect local health for client. Errors of collection are only
+// logged, info contains all, what was read
+func (s *AtellaServer) getInfo(c *ServerClient) *AtellaConfig.HostInfo {
+	info, err := s.configuration.GetInfo()
+	if err != nil {
+		s.configuration.Logger.LogWarning(fmt.Sprintf(
+			"[Server] Client [%d] info - %s", c.params.id, err))
+	}
+	return info
+}
+
+// Listen for connections. Connections are accepted by scheduled routine
+// until server stopped
 func (s *AtellaServer) Listen() {
 	var listener *net.TCPListener
 	var err error
 	address, err := net.ResolveTCPAddr("tcp", s.address)
-	if s.tlsConfig == nil {
+	if err == nil {
 		listener, err = net.ListenTCP("tcp", address)
-	} else {
-		s.configuration.Logger.LogFatal("[Server] Tls server are not implemented")
-		// listener, err = tls.ListenTCP("tcp", address, s.tlsConfig)
 	}
 	if err != nil {
 		s.configuration.Logger.LogFatal(fmt.Sprintf("[Server] Error starting TCP server. %s", err))
 	}
-	defer listener.Close()
 
+	s.scheduler.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		s.configuration.Logger.LogSystem("[Server] Stopping server")
+		listener.Close()
+	})
+	s.scheduler.Go(func(ctx context.Context) {
+		s.accept(ctx, listener)
+	})
+}
+
+// Function accept connections and run client routines
+func (s *AtellaServer) accept(ctx context.Context, listener net.Listener) {
 	for {
-		select {
-		case <-s.stopRequest:
-			s.configuration.Logger.LogSystem("[Server] Stopping server")
-			s.CloseReplyServer = true
-			return
-		default:
-		}
-		listener.SetDeadline(time.Now().Add(1e9))
 		conn, err := listener.Accept()
 		if err != nil {
-			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
-				continue
+			if ctx.Err() != nil {
+				return
 			}
 			s.configuration.Logger.LogError(
 				fmt.Sprintf("[Server] Failed to accept connection: %s", err.Error()))
 			continue
 		}
+		// Handshake are made by client routine
+		if s.tlsConfig != nil {
+			conn = tls.Server(conn, s.tlsConfig)
+		}
 		client := &ServerClient{
 			conn:   conn,
 			Server: s,
 			params: clientParams{
 				canTalk:         false,
 				emptyMessageCnt: 0}}
-		go client.listen()
+		// Ids are given by accepting routine only
+		s.OnNewClient(client)
+		s.scheduler.Go(client.listen)
 	}
 }
 
@@ -280,33 +413,30 @@ func New(c *AtellaConfig.Config, address string) *AtellaServer {
 	c.Logger.LogSystem(fmt.Sprintf("[Server] Init server side with address %s",
 		address))
 	server := &AtellaServer{
-		address:          address,
-		tlsConfig:        nil,
-		configuration:    c,
-		stopRequest:      make(chan struct{}),
-		reloadRequest:    make(chan struct{}),
-		CloseReplyServer: false,
-		CloseReplyMaster: false}
+		address:       address,
+		tlsConfig:     nil,
+		configuration: c,
+		reloadRequest: make(chan struct{}),
+		scheduler:     AtellaConfig.NewScheduler(context.Background()),
+		problems:      make(map[string]map[string]string)}
 	return server
 }
 
-// Function for stopping server
+// Function for stopping server. Wait until all routines finish
 func (s *AtellaServer) Stop() {
-	close(s.stopRequest)
-	for !s.CloseReplyServer || !s.CloseReplyMaster {
-	}
+	s.scheduler.Stop()
 	s.configuration.Logger.LogSystem("[Server] Server stopped")
 }
 
-// func NewWithTLS(address string, certFile string, keyFile string) *AtellaServer {
-// 	AtellaLogger.LogInfo(fmt.Sprintf("Init tls server side with address %s", address))
-// 	cert, _ := tls.LoadX509KeyPair(certFile, keyFile)
-// 	config := tls.Config{
-// 		Certificates: []tls.Certificate{cert},
-// 	}
-// 	server := &AtellaServer{
-// 		address: address,
-// 		config:  &config,
-// 	}
-// 	return server
-// }
+// Create new server with TLS
+func NewWithTLS(c *AtellaConfig.Config, address string) *AtellaServer {
+	c.Logger.LogSystem(fmt.Sprintf("[Server] Init tls server side with address %s",
+		address))
+	config, err := c.GetServerTLSConfig()
+	if err != nil {
+		c.Logger.LogFatal(fmt.Sprintf("[Server] Error loading TLS config. %s", err))
+	}
+	server := New(c, address)
+	server.tlsConfig = config
+	return server
+}
@@ -8,10 +8,12 @@ import (
 	"runtime"
 	"syscall"
 
+	"../../AtellaCheck"
 	"../../AtellaCli"
 	"../../AtellaClient"
 	"../../AtellaConfig"
 	"../../AtellaDatabase"
+	"../../AtellaHttp"
 	"../../AtellaLogger"
 	"../../AtellaServer"
 )
@@ -22,6 +24,7 @@ var (
 	configDirPath  string                     = ""
 	client         *AtellaClient.ServerClient = nil
 	server         *AtellaServer.AtellaServer = nil
+	api            *AtellaHttp.AtellaHttp     = nil
 	printVersion   bool                       = false
 	GitCommit      string                     = "unknown"
 	GoVersion      string                     = "unknown"
@@ -32,6 +35,7 @@ var (
 	BinPrefix      string                     = "/usr/bin"
 	ScriptsPrefix  string                     = "/usr/lib/atella/scripts"
 	stop           bool                       = false
+	shutdown       chan struct{}              = make(chan struct{})
 )
 
 // Interrupts handler
@@ -55,19 +59,22 @@ func handle(c chan os.Signal) {
 			client.Reload(conf)
 			AtellaDatabase.Reload(conf)
 			conf.Logger.LogSystem(fmt.Sprintf("[%s] Reloaded", Service))
-		case "interrupt":
+		case "interrupt", "terminated":
 			if !stop {
 				stop = true
 				server.Stop()
+				api.Stop()
 				client.Stop()
 				conf.StopSender()
+				conf.SaveBootState(true)
+				// Main routine exits, when shutdown is complete
+				close(shutdown)
 			} else {
 				if conf != nil {
 					conf.Logger.LogSystem(fmt.Sprintf("[%s] Already in Progress",
 						Service))
 				}
 			}
-			os.Exit(0)
 		case "user defined signal 1":
 			conf.Send()
 		case "user defined signal 2":
@@ -113,6 +120,7 @@ func initFlags() {
 	AtellaConfig.Sys = Sys
 	AtellaConfig.BinPrefix = BinPrefix
 	AtellaConfig.ScriptsPrefix = ScriptsPrefix
+	AtellaCheck.ScriptsPrefix = ScriptsPrefix
 }
 
 func main() {
@@ -133,6 +141,7 @@ func main() {
 	conf.PrintJsonConfig()
 
 	conf.SavePid()
+	conf.DetectBoot()
 
 	pkgName := fmt.Sprintf(AtellaCli.PkgTemplate,
 		AtellaConfig.Version, AtellaConfig.Arch, AtellaConfig.Sys)
@@ -150,18 +159,29 @@ func main() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGHUP)
 	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
 	signal.Notify(c, syscall.SIGUSR1)
 	signal.Notify(c, syscall.SIGUSR2)
 
 	go handle(c)
 	conf.Logger.LogSystem(fmt.Sprintf("[%s] Started %s version %s",
 		Service, AtellaConfig.Service, AtellaConfig.Version))
-	server = AtellaServer.New(conf, "0.0.0.0:5223")
-	go server.Listen()
-	go server.MasterServer()
+	if conf.TLSEnabled() {
+		server = AtellaServer.NewWithTLS(conf, "0.0.0.0:5223")
+	} else {
+		server = AtellaServer.New(conf, "0.0.0.0:5223")
+	}
+	server.Listen()
+	server.MasterServer()
+
+	api = AtellaHttp.New(conf)
+	go api.Listen()
 
 	client = AtellaClient.New(conf)
-	go client.Run()
+	client.Run()
 
+	conf.MetricsSender()
+	conf.BootStateSaver()
 	conf.Sender()
+	<-shutdown
 }
@@ -5,12 +5,64 @@
   log_file = "/var/log/atella/atella.log"
   pid_file = "/usr/share/atella/atella.pid"
   proc_file = "/usr/share/atella/atella.proc"
+  # Boot id and uptime are saved here to detect host reboots
+  state_file = "/usr/share/atella/atella.state"
   host_cnt = 1
   hex_len = 10
   message_path = "/usr/share/atella/msg"
   master = false
   interval = 10
   net_timeout = 2
+  # Percent of reporters, which must see host down to declare it down
+  quorum = 50
+  # Reporters, which didn't send vector during reporter_expire intervals,
+  # are not counted in verdicts. 0 - never expire
+  reporter_expire = 3
+  # Protocol for requests to other agents. Possible values:
+  #   auto - json if other agent supports it by hello, else text
+  #   text, json - forced protocol
+  protocol = "auto"
+  # Address of HTTP JSON API, e.g. "0.0.0.0:5280". Empty - disabled.
+  # Requests must have header "Authorization: Bearer {code or token}".
+  # Prometheus metrics are served on /metrics with the same auth
+  http_address = ""
+  # Neighbour goes up after rise_count consecutive successful probes and
+  # goes down after fail_count consecutive failed probes
+  rise_count = 2
+  fail_count = 3
+  # Neighbour, which changed status more than flap_count times during
+  # flap_window seconds, is flapping. Its status changes are not reported.
+  # 0 - flap detection disabled
+  flap_count = 4
+  flap_window = 600
+  # Count of last probes, used for min/avg/max of dial and round-trip time
+  rtt_window = 10
+  # Without master servers agent reports status changes and health problems
+  # of neighbours itself. Change is reported only by first alive host
+  # clockwise in sector ring. Reports about status of one neighbour are sent
+  # not often than once in alert_cooldown seconds
+  local_alerts = false
+  alert_cooldown = 300
+  # Send vector to all master servers in parallel instead of one of them
+  # with failover
+  master_fanout = false
+  # Only leader of master servers reports. Leader is first master in
+  # master_servers list, which was seen during leader_lease seconds
+  leader_lease = 30
+  # Thresholds of host health, checked by neighbours and reported to master.
+  # Memory and disk - used space in percents, load - load average for
+  # 1 minute per cpu. 0 - disabled
+  load_threshold = 0.0
+  memory_threshold = 95.0
+  disk_threshold = 90.0
+  # Checks of local host, made by agent itself every interval. Options are
+  # the same as of sector checks, {host} is 127.0.0.1 and {hostname} is
+  # hostname of agent. Changes of check state are reported via channels
+  # [[agent.local_checks]]
+  #   name = "raid"
+  #   type = "script"
+  #   script = "check_raid.sh"
+  #   interval = 300
   
 # [channels.TgSibnet]
 #   address = "localhost"
@@ -30,3 +82,15 @@
 #   from = "atella@hostname"
 #   to = ["username@domain.com"]
 #   disabled = false
+
+# Graphite channel is not in "all" target, it receives reports only if
+# they are sent to it explicitly, e.g. atella-cli -channel Graphite
+# Plaintext protocol can't carry text, so report is sent only as
+# increment of counter <prefix>.<hostname>.events.report, text of report
+# is lost. Vector metrics are sent every interval
+# [channels.Graphite]
+#   address = "localhost"
+#   port = 2003
+#   protocol = "tcp"
+#   prefix = "atella"
+#   disabled = false
@@ -1,2 +1,17 @@
+# Masters replicate master vector to each other, so every master
+# has view of whole cluster. Hostname identifies master itself.
+# Replication are accepted only from listed masters with admin secret:
+# master is recognized by hostname of hmac auth or by address.
+# Only leader - first alive master of list - sends reports.
+# Agent sends vector to master with highest priority, then to master
+# with best score (success rate and latency of last deliveries).
+# Untried master has mean score of others. Deliveries to master, which
+# was not tried for rebalance_interval seconds, expire, so failed master
+# is tried again. Every rebalance_interval seconds agent tries to return
+# to preferred master
 # [master_servers]
 #   hosts = ["ip hostname"]
+#   rebalance_interval = 300
+#   [[master_servers.priorities]]
+#     host = "hostname"
+#     priority = 10
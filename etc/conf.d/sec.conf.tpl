@@ -1,2 +1,31 @@
 # [security]
-#   code = "CodePhrase"
\ No newline at end of file
+#   code = "CodePhrase"
+#   Auth mode. Possible values:
+#     plain - code phrase are sent as is
+#     hmac - only challenge-response HMAC(code, nonce||hostname) are used
+#     compat - challenge-response are used, but code phrase are accepted
+#              from old agents
+#   auth_mode = "compat"
+#   In compat mode send code phrase to old servers, which reject challenge.
+#   Code phrase may be intercepted by fake server, so it is disabled by
+#   default. Every fallback is logged. Until all hosts are upgraded, new
+#   agents can check and report to old agents only with fallback enabled
+#   allow_plain_fallback = false
+#   Connection are closed after this count of failed auth. 0 - unlimited
+#   max_auth_failures = 3
+#   If cert and key are specifyed, agent protocol works over TLS.
+#   If ca is specifyed, peers certificates are verified with it, else
+#   system pool is used. Server requires client certificates signed by ca.
+#   cert = "/etc/atella/ssl/atella.crt"
+#   key = "/etc/atella/ssl/atella.key"
+#   ca = "/etc/atella/ssl/ca.crt"
+#   Named access tokens. Code phrase has admin role. Possible roles:
+#     read - export and get commands
+#     agent - read commands and set host, set vector
+#             Vector is accepted only of host, which is hostname of hmac
+#             auth or name of token
+#     admin - any commands, including set master of replication
+#   [[security.tokens]]
+#     name = "dashboard"
+#     token = "ReadOnlyToken"
+#     role = "read"
@@ -1,2 +1,43 @@
 # [sectors.sector1]
 #   hosts = ["ip hostname"]
+#   Extra checks of sector hosts, made by their neighbours. Results are saved
+#   into vector as checks of host. Hosts - addresses or hostnames of sector
+#   hosts, which are checked; empty - all hosts of sector. Timeout - seconds,
+#   0 - net_timeout. In url, query, server, expect and server_name {host} is
+#   replaced by address of host and {hostname} by its hostname.
+#   [[sectors.sector1.checks]]
+#     name = "ssh"
+#     type = "tcp"
+#     port = 22
+#   [[sectors.sector1.checks]]
+#     name = "site"
+#     type = "http"
+#     hosts = ["hostname"]
+#     url = "http://{host}/health"
+#     status = 200
+#     body = "ok"
+#   [[sectors.sector1.checks]]
+#     name = "resolve"
+#     type = "dns"
+#     query = "{hostname}"
+#     server = ""
+#     expect = "{host}"
+#   [[sectors.sector1.checks]]
+#     name = "certificate"
+#     type = "tls"
+#     port = 443
+#     server_name = "{hostname}"
+#     min_days = 14
+#   Nagios-style script: exit code 0/1/2/3 means ok/warning/critical/unknown,
+#   first line of stdout is the message. Relative script path is resolved
+#   against scripts directory. Script gets ATELLA_HOST and ATELLA_HOSTNAME
+#   in environment. Interval - seconds between runs, 0 - every probe.
+#   Changes of check state are reported via channels.
+#   Scripts of local host only are set in [[agent.local_checks]].
+#   [[sectors.sector1.checks]]
+#     name = "disk"
+#     type = "script"
+#     script = "check_disk.sh"
+#     args = ["{host}", "90"]
+#     interval = 60
+#     timeout = 10